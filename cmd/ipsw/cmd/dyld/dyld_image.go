@@ -87,6 +87,7 @@ var ImageCmd = &cobra.Command{
 			return err
 		}
 		defer f.Close()
+		f.ResolveClosureNames = true // only a single closure/loader is printed
 
 		// if err := f.ForEachLaunchLoaderSet(func(execPath string, pset *dyld.PrebuiltLoaderSet) {
 		// 	fmt.Println(pset.String(f))
@@ -99,7 +100,11 @@ var ImageCmd = &cobra.Command{
 		if len(args) > 1 {
 			if image, err := f.Image(args[1]); err == nil {
 				if pbl, err := f.GetDylibPrebuiltLoader(image.Name); err == nil {
-					fmt.Println(pbl.String(f))
+					if viper.GetBool("verbose") {
+						fmt.Println(pbl.StringVerbose(f))
+					} else {
+						fmt.Println(pbl.String(f))
+					}
 				} else {
 					if !errors.Is(err, dyld.ErrPrebuiltLoaderSetNotSupported) {
 						return fmt.Errorf("failed parsing launch loader sets: %v", err)
@@ -17,6 +17,7 @@ import (
 	mtypes "github.com/blacktop/go-macho/types"
 	"github.com/blacktop/ipsw/internal/utils"
 	"github.com/blacktop/ipsw/pkg/disass"
+	"golang.org/x/exp/mmap"
 )
 
 // Known good magic
@@ -79,6 +80,13 @@ type File struct {
 
 	AddressToSymbol map[uint64]string
 
+	// TolerateUnknownLoaders makes PrebuiltLoaderSet parsing keep loaders with an unknown magic as placeholders
+	// (PrebuiltLoader.Unknown) instead of failing, so closures of newer caches can still be enumerated
+	TolerateUnknownLoaders bool
+	// ResolveClosureNames makes PrebuiltLoaderSet parsing resolve objc selector and swift type names from the cache
+	// (one cache read per name, so it is off by default; see PrebuiltLoaderSet.ResolveNames to resolve a single set)
+	ResolveClosureNames bool
+
 	IsDyld4         bool
 	symCacheLoaded  bool
 	SubCacheInfo    []SubcacheEntry
@@ -239,6 +247,36 @@ func (f *File) Close() error {
 	return nil
 }
 
+type mmapCloser struct {
+	m *mmap.ReaderAt
+	f io.Closer
+}
+
+func (c mmapCloser) Close() error {
+	if err := c.m.Close(); err != nil {
+		return err
+	}
+	return c.f.Close()
+}
+
+// MmapSubCaches memory maps the cache (and all of its subcache) files and reads through the mappings.
+// NOTE: this is only supported for caches opened with Open
+func (f *File) MmapSubCaches() error {
+	for uuid, closer := range f.closers {
+		osf, ok := closer.(*os.File)
+		if !ok {
+			continue // already mmap'd or not opened from disk
+		}
+		m, err := mmap.Open(osf.Name())
+		if err != nil {
+			return fmt.Errorf("failed to mmap %s: %v", osf.Name(), err)
+		}
+		f.r[uuid] = m
+		f.closers[uuid] = mmapCloser{m: m, f: osf}
+	}
+	return nil
+}
+
 // ReadHeader opens a given cache and returns the dyld_cache_header
 func ReadHeader(name string) (*CacheHeader, error) {
 	var header CacheHeader
@@ -3,14 +3,22 @@ package dyld
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"os"
+	"slices"
 	"strings"
+	"time"
 	"unsafe"
 
-	"github.com/blacktop/go-macho/pkg/trie"
 	"github.com/blacktop/go-macho/types"
+	"golang.org/x/exp/mmap"
+	"golang.org/x/sync/errgroup"
 )
 
 func (f *File) SupportsPrebuiltLoaderSet() bool {
@@ -24,124 +32,571 @@ func (f *File) SupportsPrebuiltLoaderSet() bool {
 }
 
 func (f *File) ForEachLaunchLoaderSet(handler func(execPath string, pset *PrebuiltLoaderSet)) error {
-	if f.Headers[f.UUID].MappingOffset < uint32(unsafe.Offsetof(f.Headers[f.UUID].ProgramTrieSize)) {
-		return ErrPrebuiltLoaderSetNotSupported
+	return f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		handler(execPath, pset)
+		return nil
+	})
+}
+
+// ForEachLaunchLoaderSetWithProgress is like ForEachLaunchLoaderSet but also calls progress (if not nil) with the
+// number of closures parsed so far and the total number of closures in the cache.
+// NOTE: progress is called from a separate goroutine and updates are dropped while it is busy (the last one is always delivered)
+func (f *File) ForEachLaunchLoaderSetWithProgress(handler func(execPath string, pset *PrebuiltLoaderSet), progress func(done, total int)) error {
+	return f.ForEachLaunchLoaderSetFrom(0, handler, progress)
+}
+
+// ForEachLaunchLoaderSetFrom is like ForEachLaunchLoaderSetWithProgress but skips (without parsing) the first startAt
+// closures in ProgramTrie order. The done value passed to progress is the index of the next closure to parse, so it
+// can be saved as a checkpoint and passed back as startAt to resume an interrupted walk (the trie order is stable).
+func (f *File) ForEachLaunchLoaderSetFrom(startAt int, handler func(execPath string, pset *PrebuiltLoaderSet), progress func(done, total int)) error {
+	if startAt < 0 {
+		return fmt.Errorf("invalid start index %d", startAt)
 	}
-	if f.Headers[f.UUID].ProgramTrieAddr == 0 {
-		return ErrPrebuiltLoaderSetNotSupported
+
+	if progress == nil {
+		return f.forEachLaunchLoaderSetFrom(startAt, func(execPath string, pset *PrebuiltLoaderSet) error {
+			handler(execPath, pset)
+			return nil
+		})
 	}
 
-	uuid, off, err := f.GetOffset(f.Headers[f.UUID].ProgramTrieAddr)
-	if err != nil {
+	var total int
+	if err := f.forEachLaunchLoaderSetOffset(func(string, types.UUID, uint64) error {
+		total++
+		return nil
+	}); err != nil {
 		return err
 	}
 
-	dat, err := f.ReadBytesForUUID(uuid, int64(off), uint64(f.Headers[f.UUID].ProgramTrieSize))
-	if err != nil {
-		return err
+	updates := make(chan int, 1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for done := range updates {
+			progress(done, total)
+		}
+	}()
+
+	done, sent := startAt, startAt
+	err := f.forEachLaunchLoaderSetFrom(startAt, func(execPath string, pset *PrebuiltLoaderSet) error {
+		handler(execPath, pset)
+		done++
+		select {
+		case updates <- done:
+			sent = done
+		default: // the callback is still busy with a previous update
+		}
+		return nil
+	})
+
+	if sent != done {
+		updates <- done
 	}
+	close(updates)
+	<-finished
 
-	r := bytes.NewReader(dat)
+	return err
+}
 
-	nodes, err := trie.ParseTrie(r)
-	if err != nil {
-		return err
+// ForEachLaunchLoaderSetParallel is like ForEachLaunchLoaderSet but parses the closures in a pool of (at most) workers
+// goroutines and so calls handler concurrently (it MUST be goroutine-safe) and in no particular order.
+// The first error returned by handler (or from parsing a closure) cancels the walk and is returned.
+func (f *File) ForEachLaunchLoaderSetParallel(ctx context.Context, workers int, handler func(execPath string, pset *PrebuiltLoaderSet) error) error {
+	if workers < 1 {
+		return fmt.Errorf("invalid number of workers %d", workers)
 	}
 
-	for _, node := range nodes {
-		r.Seek(int64(node.Offset), io.SeekStart)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
 
-		pblsOff, err := trie.ReadUleb128(r)
-		if err != nil {
+	err := f.forEachLaunchLoaderSetAddr(func(execPath string, psetAddr uint64) error {
+		if err := gctx.Err(); err != nil {
 			return err
 		}
+		g.Go(func() error {
+			if gctx.Err() != nil {
+				return nil // the walk was already canceled
+			}
+			pset, err := f.parseLoaderSetAtAddr(psetAddr, nil) // every parse reads through its own section readers
+			if err != nil {
+				return fmt.Errorf("failed to parse closure of %s: %w", execPath, err)
+			}
+			return handler(execPath, pset)
+		})
+		return nil
+	})
+
+	if werr := g.Wait(); werr != nil {
+		return werr // the cause of any cancelation of the walk
+	}
+	return err
+}
 
-		uuid, psetOffset, err := f.GetOffset(f.Headers[f.UUID].ProgramsPblSetPoolAddr + uint64(pblsOff))
+// AllLaunchLoaderSets returns every launch PrebuiltLoaderSet in the cache keyed by exec path
+// NOTE: this keeps EVERY parsed closure in memory (which can be several GB for a full cache),
+// use ForEachLaunchLoaderSet to stream them one at a time instead
+func (f *File) AllLaunchLoaderSets() (map[string]*PrebuiltLoaderSet, error) {
+	sets := make(map[string]*PrebuiltLoaderSet)
+	if err := f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		sets[execPath] = pset
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// forEachLaunchLoaderSet is like ForEachLaunchLoaderSet but stops at the first error returned by handler
+func (f *File) forEachLaunchLoaderSet(handler func(execPath string, pset *PrebuiltLoaderSet) error) error {
+	return f.forEachLaunchLoaderSetFrom(0, handler)
+}
+
+// forEachLaunchLoaderSetFrom is like forEachLaunchLoaderSet but skips (without parsing) the first startAt closures
+func (f *File) forEachLaunchLoaderSetFrom(startAt int, handler func(execPath string, pset *PrebuiltLoaderSet) error) error {
+	var idx int
+	return f.forEachLaunchLoaderSetAddr(func(execPath string, psetAddr uint64) error {
+		idx++
+		if idx <= startAt {
+			return nil
+		}
+		pset, err := f.parseLoaderSetAtAddr(psetAddr, nil)
 		if err != nil {
 			return err
 		}
+		return handler(execPath, pset)
+	})
+}
 
-		pset, err := f.parsePrebuiltLoaderSet(io.NewSectionReader(f.r[uuid], int64(psetOffset), 1<<63-1))
+// forEachLaunchLoaderSetOffset calls handler with the subcache UUID and offset of every launch PrebuiltLoaderSet
+func (f *File) forEachLaunchLoaderSetOffset(handler func(execPath string, uuid types.UUID, psetOffset uint64) error) error {
+	return f.forEachLaunchLoaderSetAddr(func(execPath string, psetAddr uint64) error {
+		uuid, psetOffset, err := f.GetOffset(psetAddr)
 		if err != nil {
 			return err
 		}
+		return handler(execPath, uuid, psetOffset)
+	})
+}
 
-		handler(string(node.Data), pset)
+// forEachLaunchLoaderSetAddr calls handler with the (unslid) address of every launch PrebuiltLoaderSet
+func (f *File) forEachLaunchLoaderSetAddr(handler func(execPath string, psetAddr uint64) error) error {
+	sr, _, err := f.programTrie()
+	if err != nil {
+		return err
 	}
+	return walkProgramTrie(sr, sr.Size(), func(execPath string, poolOffset uint64) error {
+		return handler(execPath, f.Headers[f.UUID].ProgramsPblSetPoolAddr+poolOffset)
+	})
+}
 
-	return nil
+// programTrie returns a reader for the cache's ProgramTrie (the exec path -> ProgramsPblSetPool offset trie)
+// and the UUID of the subcache it lives in
+func (f *File) programTrie() (*io.SectionReader, types.UUID, error) {
+	if !f.SupportsPrebuiltLoaderSet() {
+		return nil, types.UUID{}, ErrPrebuiltLoaderSetNotSupported
+	}
+	uuid, off, err := f.GetOffset(f.Headers[f.UUID].ProgramTrieAddr)
+	if err != nil {
+		return nil, types.UUID{}, err
+	}
+	return io.NewSectionReader(f.r[uuid], int64(off), int64(f.Headers[f.UUID].ProgramTrieSize)), uuid, nil
 }
 
-func (f *File) ForEachLaunchLoaderSetPath(handler func(execPath string)) error {
-	if f.Headers[f.UUID].MappingOffset < uint32(unsafe.Offsetof(f.Headers[f.UUID].ProgramTrieSize)) {
-		return ErrPrebuiltLoaderSetNotSupported
+// readProgramTrie returns every exec path in the ProgramTrie mapped to its offset into the ProgramsPblSetPool
+// along with the UUID of the subcache the trie lives in.
+// NOTE: map order is random, walks that need the (stable) trie order use forEachLaunchLoaderSetAddr instead
+func (f *File) readProgramTrie() (map[string]uint64, types.UUID, error) {
+	sr, uuid, err := f.programTrie()
+	if err != nil {
+		return nil, types.UUID{}, err
 	}
-	if f.Headers[f.UUID].ProgramTrieAddr == 0 {
-		return ErrPrebuiltLoaderSetNotSupported
+	offsets := make(map[string]uint64)
+	if err := walkProgramTrie(sr, sr.Size(), func(execPath string, poolOffset uint64) error {
+		offsets[execPath] = poolOffset
+		return nil
+	}); err != nil {
+		return nil, types.UUID{}, err
 	}
+	return offsets, uuid, nil
+}
 
-	uuid, off, err := f.GetOffset(f.Headers[f.UUID].ProgramTrieAddr)
+// FindLaunchLoaderSetsByLoaderCount returns a map of exec path to loader count for every launch
+// PrebuiltLoaderSet with a loader count within [min, max] (only the set headers are read)
+func (f *File) FindLaunchLoaderSetsByLoaderCount(min, max int) (map[string]int, error) {
+	found := make(map[string]int)
+	if err := f.forEachLaunchLoaderSetOffset(func(execPath string, uuid types.UUID, psetOffset uint64) error {
+		hdr, err := f.readLoaderSetHeader(uuid, psetOffset)
+		if err != nil {
+			return fmt.Errorf("failed to read PrebuiltLoaderSet header for %s: %w", execPath, err)
+		}
+		if count := hdr.LoaderCount(); count >= min && count <= max {
+			found[execPath] = count
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// AllCacheOverrides returns a map of overridden cache dylib name to the exec paths whose closures override it
+func (f *File) AllCacheOverrides(ctx context.Context) (map[string][]string, error) {
+	overrides := make(map[string][]string)
+	if err := f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, dylib := range pset.Overrides(f) {
+			overrides[dylib] = append(overrides[dylib], execPath)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+// OptimizationStats returns the summed ObjC hash table and Swift conformance table sizes of every launch closure in the cache
+// NOTE: this parses every launch closure in the cache (O(closures))
+func (f *File) OptimizationStats(ctx context.Context) (*CacheOptStats, error) {
+	var stats CacheOptStats
+	if err := f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		stats.Add(pset)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// AllFileValidations returns the file validation info of every loader in every launch closure, i.e. a manifest
+// of every binary (and its CDHash/inode/mtime) the cache's closures expect on disk. Loaders without validation
+// info are skipped.
+// NOTE: the closure format does NOT store the binary's UUID, so records are deduplicated by path, slice and CDHash
+func (f *File) AllFileValidations() ([]FileValidationRecord, error) {
+	type key struct {
+		path   string
+		slice  uint64
+		cdhash [20]byte
+	}
+	seen := make(map[key]bool)
+	var records []FileValidationRecord
+	if err := f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		for _, pl := range pset.Loaders {
+			fv := pl.FileValidation
+			if fv == nil {
+				continue
+			}
+			k := key{path: pl.Path, slice: fv.SliceOffset, cdhash: fv.CDHash}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			rec := FileValidationRecord{
+				Path:            pl.Path,
+				ExecPath:        execPath,
+				SliceOffset:     fv.SliceOffset,
+				DeviceID:        fv.DeviceID,
+				Inode:           fv.Inode,
+				Mtime:           fv.Mtime,
+				CheckInodeMtime: fv.CheckInodeMtime,
+			}
+			if fv.CheckCDHash {
+				rec.CDHash = fv.CDHashString()
+			}
+			records = append(records, rec)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ForEachBindTargetInCache calls handler with every bind target of every loader in every launch closure in the cache
+// NOTE: closures are parsed (and released) one at a time so only a single closure is ever held in memory
+func (f *File) ForEachBindTargetInCache(ctx context.Context, handler func(execPath string, loaderIdx int, b BindTargetRef) error) error {
+	return f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for lidx, pl := range pset.Loaders {
+			for _, bt := range pl.BindTargets {
+				if err := handler(execPath, lidx, bt); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// ClosuresContainingAddress returns the exec paths of every launch closure that links the cache image containing the
+// (unslid) cache address addr (one of its loaders depends on or binds to the image).
+// NOTE: launch closures only hold app loaders (cache dylibs are on disk in the closure's eyes), so the address is
+// resolved to a cache image first and then matched against every closure's cache image refs (O(closures × refs))
+func (f *File) ClosuresContainingAddress(ctx context.Context, addr uint64) ([]string, error) {
+	img, err := f.GetImageContainingTextAddr(addr)
 	if err != nil {
-		return err
+		if img, err = f.GetImageContainingVMAddr(addr); err != nil {
+			return nil, err
+		}
+	}
+	isImage := func(ref LoaderRef) bool {
+		return !ref.IsApp() && !ref.IsMissingWeakImage() && uint32(ref.Index()) == img.Index
+	}
+	var execPaths []string
+	if err := f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, pl := range pset.Loaders {
+			if slices.ContainsFunc(pl.DependentRefs, isImage) || slices.ContainsFunc(pl.BindTargets, func(bt BindTargetRef) bool {
+				return !bt.IsAbsolute() && isImage(bt.LoaderRef())
+			}) {
+				execPaths = append(execPaths, execPath)
+				return nil
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
+	return execPaths, nil
+}
 
-	dat, err := f.ReadBytesForUUID(uuid, int64(off), uint64(f.Headers[f.UUID].ProgramTrieSize))
+// ClosuresOverriding returns the exec paths of every launch closure with a loader that roots the given cache dylib
+// (the loader has dylib patches for it) or has override bind targets into it.
+// NOTE: this parses every launch closure in the cache (O(closures))
+func (f *File) ClosuresOverriding(ctx context.Context, dylibPath string) ([]string, error) {
+	imgIdx, err := f.HasImagePath(dylibPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	var execPaths []string
+	if err := f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, pl := range pset.Loaders {
+			if len(pl.DylibPatches) > 0 && (pl.Path == dylibPath || pl.AltPath == dylibPath) {
+				execPaths = append(execPaths, execPath)
+				return nil
+			}
+			for _, bt := range pl.OverrideBindTargets {
+				if !bt.IsAbsolute() && !bt.LoaderRef().IsApp() && int(bt.LoaderRef().Index()) == imgIdx {
+					execPaths = append(execPaths, execPath)
+					return nil
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return execPaths, nil
+}
 
-	r := bytes.NewReader(dat)
+// ClosuresDependingOn returns the exec paths of every launch closure with a loader that depends on dylib
+// (matched by its real path or install-name, see PrebuiltLoaderSet.DependentsOf)
+// NOTE: this parses every launch closure in the cache (O(closures))
+func (f *File) ClosuresDependingOn(ctx context.Context, dylib string) ([]string, error) {
+	var execPaths []string
+	if err := f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(pset.DependentsOf(dylib)) > 0 {
+			execPaths = append(execPaths, execPath)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return execPaths, nil
+}
 
-	nodes, err := trie.ParseTrie(r)
-	if err != nil {
-		return err
+// ClosuresByCacheUUID returns the exec paths of every launch closure in the cache grouped by the UUID of the dyld cache
+// the closure was built against (see PrebuiltLoaderSet.MatchesCache); closures that did NOT record one are grouped
+// under the null UUID's string
+// NOTE: this parses every launch closure in the cache (O(closures))
+func (f *File) ClosuresByCacheUUID(ctx context.Context) (map[string][]string, error) {
+	byUUID := make(map[string][]string)
+	if err := f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		uuid := pset.DyldCacheUUID.String()
+		byUUID[uuid] = append(byUUID[uuid], execPath)
+		return nil
+	}); err != nil {
+		return nil, err
 	}
+	return byUUID, nil
+}
+
+// StreamLaunchLoaderSetsJSON writes every launch PrebuiltLoaderSet in the cache to w as JSON Lines (one object per closure)
+func (f *File) StreamLaunchLoaderSetsJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		kinds := make(map[string]int)
+		for kind, n := range pset.DependentKindCounts() {
+			kinds[kind.String()] = n
+		}
+		if err := enc.Encode(&struct {
+			Path           string             `json:"path"`
+			DependentKinds map[string]int     `json:"dependentKinds"`
+			Closure        *PrebuiltLoaderSet `json:"closure"`
+		}{
+			Path:           execPath,
+			DependentKinds: kinds,
+			Closure:        pset,
+		}); err != nil {
+			return fmt.Errorf("failed to encode closure for %s: %w", execPath, err)
+		}
+		if fl, ok := w.(interface{ Flush() error }); ok { // flush per-record so partial output is usable
+			if err := fl.Flush(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (f *File) ForEachLaunchLoaderSetPath(handler func(execPath string)) error {
+	return f.forEachLaunchLoaderSetAddr(func(execPath string, _ uint64) error {
+		handler(execPath)
+		return nil
+	})
+}
+
+// LaunchTrieNode is a ProgramTrie entry: an executable path and the offset of its PrebuiltLoaderSet in the ProgramsPblSetPool
+type LaunchTrieNode struct {
+	Path       string
+	PoolOffset uint64
+}
 
-	for _, node := range nodes {
-		handler(string(node.Data))
+// LaunchTrieNodes returns every entry of the ProgramTrie in trie order
+func (f *File) LaunchTrieNodes() ([]LaunchTrieNode, error) {
+	var nodes []LaunchTrieNode
+	if err := f.forEachLaunchLoaderSetAddr(func(execPath string, psetAddr uint64) error {
+		nodes = append(nodes, LaunchTrieNode{
+			Path:       execPath,
+			PoolOffset: psetAddr - f.Headers[f.UUID].ProgramsPblSetPoolAddr,
+		})
+		return nil
+	}); err != nil {
+		return nil, err
 	}
+	return nodes, nil
+}
 
-	return nil
+// LaunchLoaderSetPaths returns a page of (at most limit) exec paths starting at offset (in ProgramTrie order)
+// along with the total number of exec paths in the cache (a limit <= 0 returns every path after offset)
+// NOTE: the whole trie is still walked to count the paths, but only the requested page is kept
+func (f *File) LaunchLoaderSetPaths(offset, limit int) ([]string, int, error) {
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("invalid page offset %d", offset)
+	}
+	var page []string
+	total := 0
+	if err := f.ForEachLaunchLoaderSetPath(func(execPath string) {
+		if total >= offset && (limit <= 0 || len(page) < limit) {
+			page = append(page, execPath)
+		}
+		total++
+	}); err != nil {
+		return nil, 0, err
+	}
+	return page, total, nil
 }
 
 // GetLaunchLoaderSet returns the PrebuiltLoaderSet for the given executable app path.
 func (f *File) GetLaunchLoaderSet(executablePath string) (*PrebuiltLoaderSet, error) {
-	if f.Headers[f.UUID].MappingOffset < uint32(unsafe.Offsetof(f.Headers[f.UUID].ProgramTrieSize)) {
-		return nil, ErrPrebuiltLoaderSetNotSupported
-	}
-	if f.Headers[f.UUID].ProgramTrieAddr == 0 {
-		return nil, ErrPrebuiltLoaderSetNotSupported
+	psetAddr, err := f.getLaunchLoaderSetAddr(executablePath)
+	if err != nil {
+		return nil, err
 	}
+	return f.parseLoaderSetAtAddr(psetAddr, nil)
+}
 
-	var psetOffset uint64
+// GetLaunchLoaderSetWithStats is like GetLaunchLoaderSet but also returns how long each parsing phase took
+func (f *File) GetLaunchLoaderSetWithStats(executablePath string) (*PrebuiltLoaderSet, *ParseStats, error) {
+	var stats ParseStats
+	start := time.Now()
+	psetAddr, err := f.getLaunchLoaderSetAddr(executablePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats.TrieWalk = time.Since(start)
+	pset, err := f.parseLoaderSetAtAddr(psetAddr, &stats)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats.Total = time.Since(start)
+	return pset, &stats, nil
+}
 
-	uuid, off, err := f.GetOffset(f.Headers[f.UUID].ProgramTrieAddr)
+// LoaderSetBytes returns the raw on-disk PrebuiltLoaderSet (closure) blob for the given executable app path.
+func (f *File) LoaderSetBytes(executablePath string) ([]byte, error) {
+	uuid, psetOffset, err := f.getLaunchLoaderSetOffset(executablePath)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := f.readLoaderSetHeader(uuid, psetOffset)
 	if err != nil {
 		return nil, err
 	}
+	return f.ReadBytesForUUID(uuid, int64(psetOffset), uint64(hdr.Length))
+}
 
-	dat, err := f.ReadBytesForUUID(uuid, int64(off), uint64(f.Headers[f.UUID].ProgramTrieSize))
+// PeekLoaderSetHeader returns ONLY the PrebuiltLoaderSet header for the given executable app path (no loaders, patches, etc. are parsed).
+func (f *File) PeekLoaderSetHeader(executablePath string) (*PrebuiltLoaderSetHeader, error) {
+	uuid, psetOffset, err := f.getLaunchLoaderSetOffset(executablePath)
 	if err != nil {
 		return nil, err
 	}
+	return f.readLoaderSetHeader(uuid, psetOffset)
+}
 
-	r := bytes.NewReader(dat)
+// readLoaderSetHeader reads and verifies the PrebuiltLoaderSet header at the given subcache offset
+func (f *File) readLoaderSetHeader(uuid types.UUID, psetOffset uint64) (*PrebuiltLoaderSetHeader, error) {
+	var hdr PrebuiltLoaderSetHeader
+	if err := binary.Read(io.NewSectionReader(f.r[uuid], int64(psetOffset), 1<<63-1), binary.LittleEndian, &hdr); err != nil {
+		return nil, err
+	}
+	hdr.trimToLayout()
+	if hdr.Magic != PrebuiltLoaderSetMagic {
+		return nil, &MagicMismatchError{Expected: PrebuiltLoaderSetMagic, Got: hdr.Magic, Offset: int64(psetOffset)}
+	}
+	return &hdr, nil
+}
 
-	if _, err = trie.WalkTrie(r, executablePath); err != nil {
-		return nil, fmt.Errorf("could not find executable %s in the ProgramTrie: %w", executablePath, err)
+// getLaunchLoaderSetOffset returns the subcache UUID and offset of the PrebuiltLoaderSet for the given executable app path.
+func (f *File) getLaunchLoaderSetOffset(executablePath string) (types.UUID, uint64, error) {
+	psetAddr, err := f.getLaunchLoaderSetAddr(executablePath)
+	if err != nil {
+		return types.UUID{}, 0, err
 	}
+	return f.GetOffset(psetAddr)
+}
 
-	poolOffset, err := trie.ReadUleb128(r)
+// getLaunchLoaderSetAddr returns the (unslid) address of the PrebuiltLoaderSet for the given executable app path.
+func (f *File) getLaunchLoaderSetAddr(executablePath string) (uint64, error) {
+	sr, _, err := f.programTrie()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	uuid, psetOffset, err = f.GetOffset(f.Headers[f.UUID].ProgramsPblSetPoolAddr + uint64(poolOffset))
+	poolOffset, err := lookupProgramTrie(sr, sr.Size(), executablePath)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("could not find executable %s in the ProgramTrie: %w", executablePath, err)
 	}
 
-	return f.parsePrebuiltLoaderSet(io.NewSectionReader(f.r[uuid], int64(psetOffset), 1<<63-1))
+	return f.Headers[f.UUID].ProgramsPblSetPoolAddr + poolOffset, nil
 }
 
 func (f *File) SupportsDylibPrebuiltLoader() bool {
@@ -157,20 +612,62 @@ func (f *File) SupportsDylibPrebuiltLoader() bool {
 	return true
 }
 
-// GetLaunchLoader returns the PrebuiltLoader for the given executable in-cache dylib path.
-func (f *File) GetDylibPrebuiltLoader(executablePath string) (*PrebuiltLoader, error) {
+// getDylibPrebuiltLoaderSetOffset returns the subcache UUID and offset of the cache dylibs PrebuiltLoaderSet.
+func (f *File) getDylibPrebuiltLoaderSetOffset() (types.UUID, uint64, error) {
+	if !f.SupportsDylibPrebuiltLoader() {
+		return types.UUID{}, 0, ErrPrebuiltLoaderSetNotSupported
+	}
+	return f.GetOffset(f.Headers[f.UUID].DylibsPblSetAddr)
+}
 
-	if f.Headers[f.UUID].MappingOffset < uint32(unsafe.Offsetof(f.Headers[f.UUID].ProgramTrieSize)) {
+// GetDylibPrebuiltLoaderSet returns the PrebuiltLoaderSet of ALL the in-cache dylibs.
+func (f *File) GetDylibPrebuiltLoaderSet() (*PrebuiltLoaderSet, error) {
+	if !f.SupportsDylibPrebuiltLoader() {
 		return nil, ErrPrebuiltLoaderSetNotSupported
 	}
-	if f.Headers[f.UUID].MappingOffset < uint32(unsafe.Offsetof(f.Headers[f.UUID].DylibsPblSetAddr)) {
-		return nil, ErrPrebuiltLoaderSetNotSupported
+	return f.parseLoaderSetAtAddr(f.Headers[f.UUID].DylibsPblSetAddr, nil)
+}
+
+// ForEachDylibPrebuiltLoader calls handler with the index and PrebuiltLoader of every in-cache dylib
+// one at a time (without parsing the whole set first) and stops at the first error returned by handler.
+func (f *File) ForEachDylibPrebuiltLoader(handler func(int, *PrebuiltLoader) error) error {
+	uuid, off, err := f.getDylibPrebuiltLoaderSetOffset()
+	if err != nil {
+		return err
 	}
-	if f.Headers[f.UUID].DylibsPblSetAddr == 0 {
-		return nil, ErrPrebuiltLoaderSetNotSupported
+
+	hdr, err := f.readLoaderSetHeader(uuid, off)
+	if err != nil {
+		return err
+	}
+
+	loaderOffsets := make([]uint32, hdr.LoadersArrayCount)
+	if err := binary.Read(io.NewSectionReader(f.r[uuid], int64(off)+int64(hdr.LoadersArrayOffset), 1<<63-1), binary.LittleEndian, &loaderOffsets); err != nil {
+		return err
+	}
+
+	for idx, loaderOffset := range loaderOffsets {
+		lr, err := f.loaderReader(nil, f.Headers[f.UUID].DylibsPblSetAddr, loaderOffset)
+		if err != nil {
+			return err
+		}
+		pbl, err := f.parsePrebuiltLoader(lr, nil)
+		if errors.Is(err, ErrJITLoader) {
+			continue // the dylibs set should only contain PrebuiltLoaders
+		} else if err != nil {
+			return fmt.Errorf("failed to parse dylib loader %d at offset %#x: %w", idx, loaderOffset, err)
+		}
+		if err := handler(idx, pbl); err != nil {
+			return err
+		}
 	}
 
-	uuid, off, err := f.GetOffset(f.Headers[f.UUID].DylibsPblSetAddr)
+	return nil
+}
+
+// GetLaunchLoader returns the PrebuiltLoader for the given executable in-cache dylib path.
+func (f *File) GetDylibPrebuiltLoader(executablePath string) (*PrebuiltLoader, error) {
+	uuid, off, err := f.getDylibPrebuiltLoaderSetOffset()
 	if err != nil {
 		return nil, err
 	}
@@ -178,9 +675,10 @@ func (f *File) GetDylibPrebuiltLoader(executablePath string) (*PrebuiltLoader, e
 	sr := io.NewSectionReader(f.r[uuid], int64(off), 1<<63-1)
 
 	var pset PrebuiltLoaderSet
-	if err := binary.Read(sr, binary.LittleEndian, &pset.prebuiltLoaderSetHeader); err != nil {
+	if err := binary.Read(sr, binary.LittleEndian, &pset.PrebuiltLoaderSetHeader); err != nil {
 		return nil, err
 	}
+	pset.PrebuiltLoaderSetHeader.trimToLayout()
 
 	sr.Seek(int64(pset.LoadersArrayOffset), io.SeekStart)
 
@@ -196,19 +694,188 @@ func (f *File) GetDylibPrebuiltLoader(executablePath string) (*PrebuiltLoader, e
 		return nil, fmt.Errorf("image not found")
 	}
 
-	sr.Seek(int64(loaderOffsets[imgIdx]), io.SeekStart)
+	if imgIdx >= len(loaderOffsets) {
+		return nil, &OffsetRangeError{Field: "dylib loader index", Offset: uint64(imgIdx), Limit: uint64(len(loaderOffsets))}
+	}
+
+	lr, err := f.loaderReader(sr, f.Headers[f.UUID].DylibsPblSetAddr, loaderOffsets[imgIdx])
+	if err != nil {
+		return nil, err
+	}
+
+	return f.parsePrebuiltLoader(lr, nil)
+}
+
+// ParseLoaderSetAt parses the PrebuiltLoaderSet at offset in r (e.g. an extracted ProgramsPblSetPool blob)
+// resolving cache dylib references against images (which can be nil).
+// NOTE: names that live in the cache itself (e.g. objc selector fixups) are NOT resolved
+func ParseLoaderSetAt(r io.ReaderAt, offset int64, images []*CacheImage) (*PrebuiltLoaderSet, error) {
+	f := &File{
+		ByteOrder: binary.LittleEndian,
+		Images:    images,
+	}
+	return f.parseLoaderSetAt(r, offset)
+}
+
+// OpenClosureFile parses a standalone closure file (e.g. written by `dyld_closure_util -create_closure`) resolving
+// cache dylib refs against images (which may be nil). The file is either a bare PrebuiltLoaderSet or a container
+// with one embedded in it, in which case the first (4-byte aligned) PrebuiltLoaderSet magic whose set fits in the
+// file is used.
+func OpenClosureFile(path string, images []*CacheImage) (*PrebuiltLoaderSet, error) {
+	m, err := mmap.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mmap %s: %w", path, err)
+	}
+	defer m.Close()
+
+	offset, err := findLoaderSet(m, int64(m.Len()))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ParseLoaderSetAt(m, offset, images)
+}
+
+// findLoaderSet returns the offset of the first PrebuiltLoaderSet in r (of the given size)
+func findLoaderSet(r io.ReaderAt, size int64) (int64, error) {
+	hdrSize := int64(binary.Size(PrebuiltLoaderSetHeader{}))
+	var magic [4]byte
+	var hdr PrebuiltLoaderSetHeader
+	for off := int64(0); off+hdrSize <= size; off += 4 {
+		if _, err := r.ReadAt(magic[:], off); err != nil {
+			return 0, err
+		}
+		if binary.LittleEndian.Uint32(magic[:]) != PrebuiltLoaderSetMagic {
+			continue
+		}
+		if off == 0 {
+			return 0, nil // a bare closure
+		}
+		if err := binary.Read(io.NewSectionReader(r, off, hdrSize), binary.LittleEndian, &hdr); err != nil {
+			return 0, err
+		}
+		if hdr.Length >= uint32(hdrSize) && off+int64(hdr.Length) <= size {
+			return off, nil
+		}
+	}
+	var got uint32
+	if size >= 4 {
+		if _, err := r.ReadAt(magic[:], 0); err != nil {
+			return 0, err
+		}
+		got = binary.LittleEndian.Uint32(magic[:])
+	}
+	return 0, &MagicMismatchError{Expected: PrebuiltLoaderSetMagic, Got: got}
+}
+
+// NamedLoaderSet is a launch PrebuiltLoaderSet along with the exec path it belongs to
+type NamedLoaderSet struct {
+	ExecPath string
+	*PrebuiltLoaderSet
+}
+
+// newSingleFileCache returns a File for the (non-split) cache in r with just its header and mappings read
+func newSingleFileCache(r io.ReaderAt, images []*CacheImage) (*File, error) {
+	sr := io.NewSectionReader(r, 0, 1<<63-1)
+
+	var hdr CacheHeader
+	if err := binary.Read(sr, binary.LittleEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("failed to read cache header: %w", err)
+	}
+	f := &File{
+		UUID:      hdr.UUID,
+		Headers:   map[types.UUID]CacheHeader{hdr.UUID: hdr},
+		ByteOrder: binary.LittleEndian,
+		Images:    images,
+		r:         map[types.UUID]io.ReaderAt{hdr.UUID: r},
+		Mappings:  make(map[types.UUID]cacheMappings),
+	}
+
+	sr.Seek(int64(hdr.MappingOffset), io.SeekStart)
+	for i := uint32(0); i != hdr.MappingCount; i++ {
+		var cmInfo CacheMappingInfo
+		if err := binary.Read(sr, binary.LittleEndian, &cmInfo); err != nil {
+			return nil, fmt.Errorf("failed to read cache mapping %d: %w", i, err)
+		}
+		f.Mappings[hdr.UUID] = append(f.Mappings[hdr.UUID], &CacheMapping{CacheMappingInfo: cmInfo})
+	}
+
+	return f, nil
+}
+
+// ParseAllClosures parses every launch closure of a dyld_shared_cache read from a stream (e.g. while it is being
+// downloaded or decompressed) without requiring the cache to be on the filesystem. images are the cache's
+// images (used to resolve names) and may be nil.
+// NOTE: the ProgramTrie and PrebuiltLoaderSets need random access, so the stream is first buffered to a temp
+// file (which needs as much free disk space as the cache) and only the cache's own mappings are used, so
+// closures in split cache's subcaches are NOT reachable
+func ParseAllClosures(r io.Reader, images []*CacheImage) ([]*NamedLoaderSet, error) {
+	tmp, err := os.CreateTemp("", "dyld_shared_cache_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file to buffer the cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return nil, fmt.Errorf("failed to buffer the cache: %w", err)
+	}
+
+	f, err := newSingleFileCache(tmp, images)
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []*NamedLoaderSet
+	if err := f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		sets = append(sets, &NamedLoaderSet{ExecPath: execPath, PrebuiltLoaderSet: pset})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+func (f *File) parseLoaderSetAt(r io.ReaderAt, offset int64) (*PrebuiltLoaderSet, error) {
+	return f.parsePrebuiltLoaderSet(io.NewSectionReader(r, offset, 1<<63-1), 0, nil)
+}
+
+// parseLoaderSetAtAddr parses the PrebuiltLoaderSet at the given (unslid) cache address
+func (f *File) parseLoaderSetAtAddr(psetAddr uint64, stats *ParseStats) (*PrebuiltLoaderSet, error) {
+	uuid, off, err := f.GetOffset(psetAddr)
+	if err != nil {
+		return nil, err
+	}
+	return f.parsePrebuiltLoaderSet(io.NewSectionReader(f.r[uuid], int64(off), 1<<63-1), psetAddr, stats)
+}
 
-	return f.parsePrebuiltLoader(io.NewSectionReader(f.r[uuid], int64(off)+int64(loaderOffsets[imgIdx]), 1<<63-1))
+// loaderReader returns a reader for the loader at loaderOffset in the set. If the set is in the cache (psetAddr != 0)
+// the loader's address is translated on its own as a set can span a subcache boundary (so sr can NOT be used)
+func (f *File) loaderReader(sr *io.SectionReader, psetAddr uint64, loaderOffset uint32) (*io.SectionReader, error) {
+	if psetAddr == 0 {
+		return io.NewSectionReader(sr, int64(loaderOffset), 1<<63-1), nil
+	}
+	uuid, off, err := f.GetOffset(psetAddr + uint64(loaderOffset))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offset of loader at set offset %#x: %w", loaderOffset, err)
+	}
+	return io.NewSectionReader(f.r[uuid], int64(off), 1<<63-1), nil
 }
 
-func (f *File) parsePrebuiltLoaderSet(sr *io.SectionReader) (*PrebuiltLoaderSet, error) {
+// parsePrebuiltLoaderSet parses the PrebuiltLoaderSet in sr; psetAddr is the set's cache address (or 0 if it is not
+// in the cache) and stats is optional and only filled in if NOT nil
+func (f *File) parsePrebuiltLoaderSet(sr *io.SectionReader, psetAddr uint64, stats *ParseStats) (*PrebuiltLoaderSet, error) {
+	if stats != nil {
+		defer func(start time.Time) { stats.SetParse += time.Since(start) }(time.Now())
+	}
+
 	var pset PrebuiltLoaderSet
-	if err := binary.Read(sr, binary.LittleEndian, &pset.prebuiltLoaderSetHeader); err != nil {
+	if err := binary.Read(sr, binary.LittleEndian, &pset.PrebuiltLoaderSetHeader); err != nil {
 		return nil, err
 	}
+	pset.PrebuiltLoaderSetHeader.trimToLayout()
 
 	if pset.Magic != PrebuiltLoaderSetMagic {
-		return nil, fmt.Errorf("invalid magic for PrebuiltLoaderSet: expected %x got %x", PrebuiltLoaderSetMagic, pset.Magic)
+		return nil, &MagicMismatchError{Expected: PrebuiltLoaderSetMagic, Got: pset.Magic}
 	}
 
 	sr.Seek(int64(pset.LoadersArrayOffset), io.SeekStart)
@@ -219,12 +886,100 @@ func (f *File) parsePrebuiltLoaderSet(sr *io.SectionReader) (*PrebuiltLoaderSet,
 	}
 
 	for _, loaderOffset := range loaderOffsets {
-		pbl, err := f.parsePrebuiltLoader(io.NewSectionReader(sr, int64(loaderOffset), 1<<63-1))
+		var start time.Time
+		if stats != nil {
+			start = time.Now()
+		}
+		lr, err := f.loaderReader(sr, psetAddr, loaderOffset)
 		if err != nil {
 			return nil, err
 		}
+		pbl, err := f.parsePrebuiltLoader(lr, stats)
+		if stats != nil {
+			stats.LoaderParse += time.Since(start)
+			stats.Loaders++
+		}
+		if errors.Is(err, ErrJITLoader) {
+			if stats != nil {
+				stats.JITLoaders++
+			}
+			// keep a placeholder with just the Loader header so LoaderRef indices still line up
+			var ldr Loader
+			if err := binary.Read(io.NewSectionReader(lr, 0, 1<<63-1), binary.LittleEndian, &ldr); err != nil {
+				return nil, err
+			}
+			pset.Loaders = append(pset.Loaders, PrebuiltLoader{
+				prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: ldr, IndexOfTwin: NoUnzipperedTwin},
+			})
+			continue
+		} else if err != nil {
+			var merr *MagicMismatchError
+			if errors.As(err, &merr) {
+				merr.Offset = int64(loaderOffset)
+				if f.TolerateUnknownLoaders {
+					if stats != nil {
+						stats.UnknownLoaders++
+					}
+					// keep a placeholder with the raw (unknown) Loader header so the rest of the set can still be listed
+					var ldr Loader
+					if err := binary.Read(io.NewSectionReader(lr, 0, 1<<63-1), binary.LittleEndian, &ldr); err != nil {
+						return nil, err
+					}
+					pset.Loaders = append(pset.Loaders, PrebuiltLoader{
+						prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: ldr, IndexOfTwin: NoUnzipperedTwin},
+						Unknown:              true,
+					})
+					continue
+				}
+			}
+			return nil, fmt.Errorf("failed to parse loader at offset %#x: %w", loaderOffset, err)
+		}
 		pset.Loaders = append(pset.Loaders, *pbl)
 	}
+	// a loader runs up to the next loader in the set (or the end of the set for the last one, if the set's length is known)
+	for idx, loaderOffset := range loaderOffsets {
+		end := uint32(math.MaxUint32)
+		if pset.Length > loaderOffset {
+			end = pset.Length
+		}
+		for _, other := range loaderOffsets {
+			if other > loaderOffset && other < end {
+				end = other
+			}
+		}
+		if end != math.MaxUint32 {
+			pset.Loaders[idx].size = end - loaderOffset
+		}
+		if psetAddr != 0 {
+			pset.Loaders[idx].addr = psetAddr + uint64(loaderOffset)
+		}
+	}
+	// app dependents and override targets point at the set's own loaders so they can only be named once every loader is parsed
+	aliases := pset.PathAliases()
+	for idx := range pset.Loaders {
+		pl := &pset.Loaders[idx]
+		for didx, dep := range pl.DependentRefs {
+			if didx >= len(pl.Dependents) {
+				break
+			}
+			if name, ok := pset.loaderRefName(f, dep); ok && dep.IsApp() && len(name) > 0 {
+				pl.Dependents[didx].Name = name
+			}
+			// dependents recorded by install-name (e.g. @rpath/Foo.framework/Foo) are named by the loader's real path
+			if path, ok := aliases[pl.Dependents[didx].Name]; ok && strings.HasPrefix(pl.Dependents[didx].Name, "@") {
+				pl.Dependents[didx].Name = path
+			}
+		}
+		if len(pl.OverrideBindTargets) == 0 {
+			continue
+		}
+		pl.OverrideBindTargetNames = make([]string, len(pl.OverrideBindTargets))
+		for i, bt := range pl.OverrideBindTargets {
+			if !bt.IsAbsolute() {
+				pl.OverrideBindTargetNames[i], _ = pset.loaderRefName(f, bt.LoaderRef())
+			}
+		}
+	}
 
 	if pset.CachePatchCount > 0 { // FIXME: this is in "/usr/bin/abmlite" but the values don't make sense (dyld_closure_util gets the same values)
 		sr.Seek(int64(pset.CachePatchOffset), io.SeekStart)
@@ -235,19 +990,28 @@ func (f *File) parsePrebuiltLoaderSet(sr *io.SectionReader) (*PrebuiltLoaderSet,
 	}
 	if pset.DyldCacheUuidOffset > 0 {
 		sr.Seek(int64(pset.DyldCacheUuidOffset), io.SeekStart)
-		var dcUUID types.UUID
-		if err := binary.Read(sr, binary.LittleEndian, &dcUUID); err != nil {
+		if err := binary.Read(sr, binary.LittleEndian, &pset.DyldCacheUUID); err != nil {
 			return nil, err
 		}
 	}
 	if pset.MustBeMissingPathsCount > 0 {
-		sr.Seek(int64(pset.MustBeMissingPathsOffset), io.SeekStart)
-		br := bufio.NewReader(sr)
+		// the paths can NOT run into the next section of the set
+		start := int64(pset.MustBeMissingPathsOffset)
+		size := int64(1<<63-1) - start
+		if end := pset.nextSectionOffset(pset.MustBeMissingPathsOffset); end != 0 {
+			size = int64(end) - start
+		}
+		br := bufio.NewReader(io.NewSectionReader(sr, start, size))
+		off := start
 		for i := 0; i < int(pset.MustBeMissingPathsCount); i++ {
 			s, err := br.ReadString('\x00')
 			if err != nil {
-				return nil, err
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF // truncated or unterminated
+				}
+				return nil, &MalformedEntryError{Field: "must-be-missing path", Index: i, Offset: off, Err: err}
 			}
+			off += int64(len(s))
 			pset.MustBeMissingPaths = append(pset.MustBeMissingPaths, strings.TrimSuffix(s, "\x00"))
 		}
 	}
@@ -342,6 +1106,9 @@ func (f *File) parsePrebuiltLoaderSet(sr *io.SectionReader) (*PrebuiltLoaderSet,
 		pset.ProtocolTable = &o
 	}
 	if !pset.HasOptimizedObjC() && pset.ObjcProtocolClassCacheOffset > 0 { // FIXME: this is a hack (would have panic'ed while parsing macOS 12.6.1 DSC prebuilt for /bin/ls) possibly uninitialized data
+		if f.ResolveClosureNames {
+			pset.resolveSetNames(f, stats)
+		}
 		return &pset, nil
 	}
 	if pset.HasOptimizedSwift() {
@@ -416,18 +1183,58 @@ func (f *File) parsePrebuiltLoaderSet(sr *io.SectionReader) (*PrebuiltLoaderSet,
 		}
 	}
 
+	if f.ResolveClosureNames {
+		pset.resolveSetNames(f, stats)
+	}
 	return &pset, nil
 }
 
-// parsePrebuiltLoader parses a prebuilt loader from a section reader.
-func (f *File) parsePrebuiltLoader(sr *io.SectionReader) (*PrebuiltLoader, error) {
+// ReadLoaderRegion returns the bytes backing the given region of an in-cache dylib's PrebuiltLoader
+// (zero-fill regions return a zeroed buffer the size of the region's VM extent)
+func (f *File) ReadLoaderRegion(pl *PrebuiltLoader, r Region) ([]byte, error) {
+	if r.IsZeroFill() {
+		size := uint64(r.FileSize)
+		for idx, rg := range pl.Regions {
+			if rg == r {
+				size = pl.RegionVMEnd(idx) - r.VMOffset()
+				break
+			}
+		}
+		return make([]byte, size), nil
+	}
+	if !pl.DylibInDyldCache() || pl.Ref.IsApp() {
+		return nil, fmt.Errorf("%w: %s (its regions are in the on-disk binary)", ErrLoaderNotInCache, pl.Path)
+	}
+	if int(pl.Ref.Index()) >= len(f.Images) {
+		return nil, &OffsetRangeError{Field: "loader image index", Offset: uint64(pl.Ref.Index()), Limit: uint64(len(f.Images))}
+	}
+	uuid, off, err := f.GetOffset(f.Images[pl.Ref.Index()].LoadAddress + r.VMOffset())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offset of region %s: %w", r, err)
+	}
+	m, err := f.GetMappingForOffsetForUUID(uuid, off)
+	if err != nil {
+		return nil, err
+	}
+	if off+uint64(r.FileSize) > m.FileOffset+m.Size {
+		return nil, &OffsetRangeError{Field: "region end", Offset: off + uint64(r.FileSize), Limit: m.FileOffset + m.Size}
+	}
+	return f.ReadBytesForUUID(uuid, int64(off), uint64(r.FileSize))
+}
+
+// parsePrebuiltLoader parses the PrebuiltLoader in sr (stats is optional and only filled in if NOT nil)
+func (f *File) parsePrebuiltLoader(sr *io.SectionReader, stats *ParseStats) (*PrebuiltLoader, error) {
 	var pbl PrebuiltLoader
 	if err := binary.Read(sr, binary.LittleEndian, &pbl.prebuiltLoaderHeader); err != nil {
 		return nil, err
 	}
 
 	if pbl.Magic != LoaderMagic {
-		return nil, fmt.Errorf("invalid magic for prebuilt loader: expected %x got %x", LoaderMagic, pbl.Magic)
+		return nil, &MagicMismatchError{Expected: LoaderMagic, Got: pbl.Magic}
+	}
+
+	if !pbl.IsPrebuilt() {
+		return nil, ErrJITLoader // the rest of the header is NOT a prebuilt loader header
 	}
 
 	if pbl.PathOffset > 0 {
@@ -463,27 +1270,38 @@ func (f *File) parsePrebuiltLoader(sr *io.SectionReader) (*PrebuiltLoader, error
 			return nil, err
 		}
 	}
+	if pbl.DepCount > 0 && pbl.DependentLoaderRefsArrayOffset == 0 {
+		return nil, fmt.Errorf("loader has %d dependents but no dependent LoaderRef array", pbl.DepCount)
+	}
 	if pbl.DependentLoaderRefsArrayOffset > 0 {
 		sr.Seek(int64(pbl.DependentLoaderRefsArrayOffset), io.SeekStart)
 		depsArray := make([]LoaderRef, pbl.DepCount)
 		if err := binary.Read(sr, binary.LittleEndian, &depsArray); err != nil {
 			return nil, err
 		}
-		kindsArray := make([]DependentKind, pbl.DepCount)
+		// NOTE: the kinds array is only emitted when at least one dependent is NOT a regular dependent
+		// (i.e. a DependentKindArrayOffset of zero means every dependent is KindNormal)
+		var kindsArray []DependentKind
 		if pbl.DependentKindArrayOffset > 0 {
 			sr.Seek(int64(pbl.DependentKindArrayOffset), io.SeekStart)
+			kindsArray = make([]DependentKind, pbl.DepCount)
 			if err := binary.Read(sr, binary.LittleEndian, &kindsArray); err != nil {
 				return nil, err
 			}
 		}
+		pbl.DependentRefs = depsArray
 		for idx, dep := range depsArray {
 			img := dep.String()
-			if dep.Index() < uint16(len(f.Images)) {
+			if !dep.IsApp() && dep.Index() < uint16(len(f.Images)) { // app dependents are named by the set (see parsePrebuiltLoaderSet)
 				img = f.Images[dep.Index()].Name
 			}
+			kind := KindNormal
+			if kindsArray != nil {
+				kind = kindsArray[idx]
+			}
 			pbl.Dependents = append(pbl.Dependents, dependent{
 				Name: img,
-				Kind: kindsArray[idx],
+				Kind: kind,
 			})
 		}
 	}
@@ -503,11 +1321,11 @@ func (f *File) parsePrebuiltLoader(sr *io.SectionReader) (*PrebuiltLoader, error
 	}
 	if pbl.ObjcBinaryInfoOffset > 0 {
 		sr.Seek(int64(pbl.ObjcBinaryInfoOffset), io.SeekStart)
-		var ofi ObjCBinaryInfo
-		if err := binary.Read(sr, binary.LittleEndian, &ofi); err != nil {
+		ofi, err := readObjCBinaryInfo(sr)
+		if err != nil {
 			return nil, err
 		}
-		pbl.ObjcFixupInfo = &ofi
+		pbl.ObjcFixupInfo = ofi
 		sr.Seek(int64(pbl.ObjcBinaryInfoOffset)+int64(pbl.ObjcFixupInfo.ProtocolFixupsOffset), io.SeekStart)
 		pbl.ObjcCanonicalProtocolFixups = make([]bool, pbl.ObjcFixupInfo.ProtocolListCount)
 		if err := binary.Read(sr, binary.LittleEndian, &pbl.ObjcCanonicalProtocolFixups); err != nil {
@@ -518,8 +1336,21 @@ func (f *File) parsePrebuiltLoader(sr *io.SectionReader) (*PrebuiltLoader, error
 		if err := binary.Read(sr, binary.LittleEndian, &pbl.ObjcSelectorFixups); err != nil {
 			return nil, err
 		}
+		if f.ResolveClosureNames {
+			var start time.Time
+			if stats != nil {
+				start = time.Now()
+			}
+			pbl.resolveSelectorNames(f)
+			if stats != nil {
+				stats.SymbolResolution += time.Since(start)
+			}
+		}
+		if stats != nil {
+			stats.SelectorFixups += len(pbl.ObjcSelectorFixups)
+		}
 	}
-	if pbl.IndexOfTwin != NoUnzipperedTwin {
+	if pbl.IndexOfTwin != NoUnzipperedTwin && int(pbl.IndexOfTwin) < len(f.Images) {
 		pbl.Twin = f.Images[pbl.IndexOfTwin].Name
 	}
 	if pbl.PatchTableOffset > 0 {
@@ -530,7 +1361,7 @@ func (f *File) parsePrebuiltLoader(sr *io.SectionReader) (*PrebuiltLoader, error
 				return nil, err
 			}
 			pbl.DylibPatches = append(pbl.DylibPatches, patch)
-			if patch.Kind == endOfPatchTable {
+			if patch.Kind == DylibPatchKindEndOfTable {
 				break
 			}
 		}
@@ -538,3 +1369,234 @@ func (f *File) parsePrebuiltLoader(sr *io.SectionReader) (*PrebuiltLoader, error
 
 	return &pbl, nil
 }
+
+// LoaderBytes returns the raw on-disk bytes of a single loader of a launch or dylibs PrebuiltLoaderSet parsed from f
+// (the loader runs up to the next loader in its set). Loaders of sets NOT parsed from the cache have no location.
+func (f *File) LoaderBytes(pl *PrebuiltLoader) ([]byte, error) {
+	if pl.addr == 0 {
+		return nil, fmt.Errorf("loader %s was not parsed from the cache", pl.Path)
+	}
+	if pl.size == 0 {
+		return nil, fmt.Errorf("size of loader %s is unknown", pl.Path)
+	}
+	hdr := f.Headers[f.UUID]
+	if poolStart := hdr.ProgramsPblSetPoolAddr; pl.addr >= poolStart && pl.addr < poolStart+hdr.ProgramsPblSetPoolSize {
+		if end := pl.addr + uint64(pl.size); end > poolStart+hdr.ProgramsPblSetPoolSize {
+			return nil, fmt.Errorf("loader %s: %w", pl.Path, &OffsetRangeError{Field: "loader end", Offset: end - 1, Limit: poolStart + hdr.ProgramsPblSetPoolSize})
+		}
+	}
+	uuid, off, err := f.GetOffset(pl.addr)
+	if err != nil {
+		return nil, err
+	}
+	// the loader must not run past the (sub)cache it starts in
+	if endUUID, endOff, err := f.GetOffset(pl.addr + uint64(pl.size) - 1); err != nil || endUUID != uuid || endOff != off+uint64(pl.size)-1 {
+		return nil, fmt.Errorf("loader %s at %#x (size %#x) is NOT contiguous in the cache", pl.Path, pl.addr, pl.size)
+	}
+	return f.ReadBytesForUUID(uuid, int64(off), uint64(pl.size))
+}
+
+// getSelectorFixupName reads the selector string a selector fixup points at in the cache
+func (f *File) getSelectorFixupName(bt BindTargetRef) (string, error) {
+	if bt.IsAbsolute() || bt.LoaderRef().IsApp() || bt.LoaderRef().IsMissingWeakImage() {
+		return "", fmt.Errorf("selector fixup %#x does not target a cache dylib", uint64(bt))
+	}
+	if int(bt.LoaderRef().Index()) >= len(f.Images) {
+		return "", &OffsetRangeError{Field: "selector fixup image index", Offset: uint64(bt.LoaderRef().Index()), Limit: uint64(len(f.Images))}
+	}
+	return f.GetCString(f.Images[bt.LoaderRef().Index()].LoadAddress + bt.Offset())
+}
+
+// ResolveNames resolves the set's objc selector names (SelectorTable.Names and the loaders' ObjcSelectorFixupNames)
+// and swift type names (SwiftTypeNames) from the cache; sets are only resolved while parsing if File.ResolveClosureNames
+// is set. NOTE: this reads one cache string per name and names in app loaders can NOT be resolved
+func (pls *PrebuiltLoaderSet) ResolveNames(f *File) {
+	for idx := range pls.Loaders {
+		pls.Loaders[idx].resolveSelectorNames(f)
+	}
+	pls.resolveSetNames(f, nil)
+}
+
+// resolveSelectorNames resolves the loader's ObjcSelectorFixupNames
+func (pl *PrebuiltLoader) resolveSelectorNames(f *File) {
+	pl.ObjcSelectorFixupNames = make([]string, len(pl.ObjcSelectorFixups))
+	for idx, bt := range pl.ObjcSelectorFixups {
+		pl.ObjcSelectorFixupNames[idx], _ = f.getSelectorFixupName(bt) // unresolved selectors are left empty
+	}
+}
+
+// resolveSetNames resolves the names in the set's own objc/swift tables (NOT its loaders')
+func (pls *PrebuiltLoaderSet) resolveSetNames(f *File, stats *ParseStats) {
+	if stats != nil {
+		defer func(start time.Time) { stats.SymbolResolution += time.Since(start) }(time.Now())
+	}
+	if o := pls.SelectorTable; o != nil {
+		o.Names = make([]string, len(o.Offsets))
+		for idx, bt := range o.Offsets {
+			if bt.IsAbsolute() {
+				continue // empty slot
+			}
+			o.Names[idx], _ = f.getSelectorFixupName(bt) // selectors in app loaders can NOT be resolved
+		}
+	}
+	if pls.HasOptimizedSwift() {
+		pls.SwiftTypeNames = f.getSwiftTypeNames(pls) // unresolved type names are left out
+	}
+}
+
+// maxSwiftForeignTypeNameLength caps the length read for a foreign type's name (guards against corrupt name lengths)
+const maxSwiftForeignTypeNameLength = 0x1000
+
+// getSwiftTypeNames resolves the names of the types in the set's swift conformance tables:
+//   - type descriptors: the descriptor's relative name pointer (at offset 8 of the context descriptor)
+//   - metadata: the name of the objc class
+//   - foreign types: the descriptor's name (of ForeignDescriptorNameLength bytes)
+func (f *File) getSwiftTypeNames(pset *PrebuiltLoaderSet) map[BindTargetRef]string {
+	names := make(map[BindTargetRef]string)
+	for _, ent := range pset.SwiftTypeProtocolTable {
+		if _, ok := names[ent.Key.TypeDescriptor]; ok {
+			continue
+		}
+		if name, err := f.getSwiftTypeDescriptorName(ent.Key.TypeDescriptor); err == nil {
+			names[ent.Key.TypeDescriptor] = name
+		}
+	}
+	for _, ent := range pset.SwiftMetadataProtocolTable {
+		if _, ok := names[ent.Key.MetadataDescriptor]; ok {
+			continue
+		}
+		if addr, err := ent.Key.MetadataDescriptor.CacheAddress(f); err == nil {
+			if cls, err := f.GetObjCClass(addr); err == nil {
+				names[ent.Key.MetadataDescriptor] = cls.Name
+			}
+		}
+	}
+	for _, ent := range pset.SwiftForeignTypeProtocolTable {
+		if _, ok := names[ent.Key.ForeignDescriptor]; ok {
+			continue
+		}
+		if name, err := f.getSwiftForeignTypeName(ent.Key.ForeignDescriptor, ent.Key.ForeignDescriptorNameLength); err == nil {
+			names[ent.Key.ForeignDescriptor] = name
+		}
+	}
+	return names
+}
+
+func (f *File) readBytesAtCacheAddress(addr, size uint64) ([]byte, error) {
+	uuid, off, err := f.GetOffset(addr)
+	if err != nil {
+		return nil, err
+	}
+	return f.ReadBytesForUUID(uuid, int64(off), size)
+}
+
+func (f *File) getSwiftTypeDescriptorName(bt BindTargetRef) (string, error) {
+	addr, err := bt.CacheAddress(f)
+	if err != nil {
+		return "", err
+	}
+	dat, err := f.readBytesAtCacheAddress(addr+8, 4) // flags, parent, name
+	if err != nil {
+		return "", fmt.Errorf("failed to read swift type descriptor name offset at %#x: %w", addr+8, err)
+	}
+	return f.GetCString(uint64(int64(addr+8) + int64(int32(f.ByteOrder.Uint32(dat)))))
+}
+
+func (f *File) getSwiftForeignTypeName(bt BindTargetRef, length uint64) (string, error) {
+	if length == 0 || length > maxSwiftForeignTypeNameLength {
+		return "", &OffsetRangeError{Field: "swift foreign type name length", Offset: length, Limit: maxSwiftForeignTypeNameLength + 1}
+	}
+	addr, err := bt.CacheAddress(f)
+	if err != nil {
+		return "", err
+	}
+	dat, err := f.readBytesAtCacheAddress(addr, length)
+	if err != nil {
+		return "", fmt.Errorf("failed to read swift foreign type name at %#x: %w", addr, err)
+	}
+	if idx := bytes.IndexByte(dat, 0); idx >= 0 { // foreign names can have NUL separated related entity names
+		dat = dat[:idx]
+	}
+	return string(dat), nil
+}
+
+// ObjCClassRef is a class pointer from a loader's __objc_classlist
+type ObjCClassRef struct {
+	Address uint64 // the (unslid) address of the class
+	Name    string // empty if the class could NOT be resolved
+}
+
+// GetLoaderObjCClasses reads the __objc_classlist of an in-cache dylib's loader and resolves each class pointer
+// to its name using the cache's objc metadata
+func (f *File) GetLoaderObjCClasses(pl *PrebuiltLoader) ([]ObjCClassRef, error) {
+	if pl.ObjcFixupInfo == nil || pl.ObjcFixupInfo.ClassListCount == 0 {
+		return nil, nil
+	}
+	dat, err := f.ReadLoaderRegion(pl, Region{
+		Info:     pl.ObjcFixupInfo.ClassListRuntimeOffset, // vmOffset (no perms/flags)
+		FileSize: pl.ObjcFixupInfo.ClassListCount * 8,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read __objc_classlist: %w", err)
+	}
+	classes := make([]ObjCClassRef, 0, pl.ObjcFixupInfo.ClassListCount)
+	for i := 0; i+8 <= len(dat); i += 8 {
+		ref := ObjCClassRef{Address: f.SlideInfo.SlidePointer(binary.LittleEndian.Uint64(dat[i:]))}
+		if cls, err := f.GetObjCClass(ref.Address); err == nil {
+			ref.Name = cls.Name
+		}
+		classes = append(classes, ref)
+	}
+	return classes, nil
+}
+
+// ObjCImageInfo reads the objc_image_info of an in-cache dylib's loader.
+// Loaders of on-disk binaries (i.e. most loaders with objc binary info in launch closures) return an error wrapping
+// ErrLoaderNotInCache as their image info is ONLY in the binary itself (see PrebuiltLoader.ReadObjCImageInfo)
+func (f *File) ObjCImageInfo(pl *PrebuiltLoader) (*ObjCImageInfoData, error) {
+	if pl.ObjcFixupInfo == nil || pl.ObjcFixupInfo.ImageInfoRuntimeOffset == 0 {
+		return nil, fmt.Errorf("loader %s has no __objc_imageinfo", pl.Path)
+	}
+	var info ObjCImageInfoData
+	dat, err := f.ReadLoaderRegion(pl, Region{
+		Info:     pl.ObjcFixupInfo.ImageInfoRuntimeOffset, // vmOffset (no perms/flags)
+		FileSize: uint32(binary.Size(info.ImageInfo)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := binary.Read(bytes.NewReader(dat), binary.LittleEndian, &info.ImageInfo); err != nil {
+		return nil, fmt.Errorf("failed to read objc_image_info: %w", err)
+	}
+	return &info, nil
+}
+
+// readObjCBinaryInfo reads an ObjCBinaryInfo field-by-field so the on-disk layout is pinned regardless of Go's struct padding
+func readObjCBinaryInfo(r io.Reader) (*ObjCBinaryInfo, error) {
+	var dat [objCBinaryInfoSize]byte
+	if _, err := io.ReadFull(r, dat[:]); err != nil {
+		return nil, fmt.Errorf("failed to read ObjCBinaryInfo: %w", err)
+	}
+	return &ObjCBinaryInfo{
+		ImageInfoRuntimeOffset:             binary.LittleEndian.Uint64(dat[0:]),
+		SelRefsRuntimeOffset:               binary.LittleEndian.Uint64(dat[8:]),
+		ClassListRuntimeOffset:             binary.LittleEndian.Uint64(dat[16:]),
+		CategoryListRuntimeOffset:          binary.LittleEndian.Uint64(dat[24:]),
+		ProtocolListRuntimeOffset:          binary.LittleEndian.Uint64(dat[32:]),
+		SelRefsCount:                       binary.LittleEndian.Uint32(dat[40:]),
+		ClassListCount:                     binary.LittleEndian.Uint32(dat[44:]),
+		CategoryCount:                      binary.LittleEndian.Uint32(dat[48:]),
+		ProtocolListCount:                  binary.LittleEndian.Uint32(dat[52:]),
+		HasClassStableSwiftFixups:          dat[56] != 0,
+		HasClassMethodListsToSetUniqued:    dat[57] != 0,
+		HasCategoryMethodListsToSetUniqued: dat[58] != 0,
+		HasProtocolMethodListsToSetUniqued: dat[59] != 0,
+		HasClassMethodListsToUnique:        dat[60] != 0,
+		HasCategoryMethodListsToUnique:     dat[61] != 0,
+		HasProtocolMethodListsToUnique:     dat[62] != 0,
+		// dat[63] is padding
+		ProtocolFixupsOffset:           binary.LittleEndian.Uint32(dat[64:]),
+		SelectorReferencesFixupsOffset: binary.LittleEndian.Uint32(dat[68:]),
+		SelectorReferencesFixupsCount:  binary.LittleEndian.Uint32(dat[72:]),
+	}, nil
+}
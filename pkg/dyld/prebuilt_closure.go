@@ -0,0 +1,127 @@
+package dyld
+
+import (
+	"sync"
+
+	"github.com/blacktop/go-macho/types"
+)
+
+// Closure is the launch closure (PrebuiltLoaderSet) of a single executable along with the cache it came from
+type Closure struct {
+	*PrebuiltLoaderSet
+	ExecPath string
+	UUID     types.UUID // the UUID of the (sub)cache the PrebuiltLoaderSet lives in
+	Offset   uint64     // the file offset of the PrebuiltLoaderSet in that (sub)cache
+
+	f *File
+}
+
+// OpenClosure finds and parses the launch closure of execPath
+func (f *File) OpenClosure(execPath string) (*Closure, error) {
+	psetAddr, err := f.getLaunchLoaderSetAddr(execPath)
+	if err != nil {
+		return nil, err
+	}
+	uuid, off, err := f.GetOffset(psetAddr)
+	if err != nil {
+		return nil, err
+	}
+	pset, err := f.parseLoaderSetAtAddr(psetAddr, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Closure{
+		PrebuiltLoaderSet: pset,
+		ExecPath:          execPath,
+		UUID:              uuid,
+		Offset:            off,
+		f:                 f,
+	}, nil
+}
+
+// File returns the dyld_shared_cache the closure was read from
+func (c *Closure) File() *File {
+	return c.f
+}
+
+// ResolveBindTarget resolves a bind target of one of the closure's loaders to the symbol it points at
+func (c *Closure) ResolveBindTarget(bt BindTargetRef) (*ResolvedSymbol, error) {
+	return c.f.ResolveBindTarget(bt)
+}
+
+// MainExecutable returns the loader of the closure's executable
+func (c *Closure) MainExecutable() (*PrebuiltLoader, bool) {
+	if pl, ok := c.PrebuiltLoaderSet.MainExecutable(); ok {
+		return pl, true
+	}
+	for i := range c.Loaders {
+		if c.Loaders[i].Path == c.ExecPath {
+			return &c.Loaders[i], true
+		}
+	}
+	return nil, false
+}
+
+func (c *Closure) String() string {
+	return c.PrebuiltLoaderSet.String(c.f)
+}
+
+// ClosureCache caches parsed launch closures of a File by exec path.
+// Entries are keyed by the cache's UUID, so if the File is re-pointed at a different cache the stale
+// entries are dropped automatically instead of being returned.
+type ClosureCache struct {
+	f    *File
+	load func(execPath string) (*PrebuiltLoaderSet, error)
+
+	mu   sync.Mutex
+	uuid types.UUID
+	sets map[string]*PrebuiltLoaderSet
+}
+
+// NewClosureCache returns an empty ClosureCache for f
+func NewClosureCache(f *File) *ClosureCache {
+	return &ClosureCache{
+		f:    f,
+		load: f.GetLaunchLoaderSet,
+		uuid: f.UUID,
+		sets: make(map[string]*PrebuiltLoaderSet),
+	}
+}
+
+// Get returns the launch closure of execPath, parsing it on first use
+func (c *ClosureCache) Get(execPath string) (*PrebuiltLoaderSet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.uuid != c.f.UUID {
+		c.invalidate()
+	}
+	if pset, ok := c.sets[execPath]; ok {
+		return pset, nil
+	}
+	pset, err := c.load(execPath)
+	if err != nil {
+		return nil, err
+	}
+	c.sets[execPath] = pset
+	return pset, nil
+}
+
+// Len returns the number of cached closures
+func (c *ClosureCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.sets)
+}
+
+// Invalidate drops every cached closure
+func (c *ClosureCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidate()
+}
+
+func (c *ClosureCache) invalidate() {
+	c.uuid = c.f.UUID
+	c.sets = make(map[string]*PrebuiltLoaderSet)
+}
@@ -0,0 +1,76 @@
+//go:build darwin && closureutil
+
+package dyld
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// closureUtilLoader is the subset of a dyld_closure_util loader that we compare against
+type closureUtilLoader struct {
+	Path       string            `json:"path"`
+	Dependents []json.RawMessage `json:"dependents"`
+}
+
+type closureUtilSet struct {
+	Loaders []closureUtilLoader `json:"loaders"`
+}
+
+// CompareWithClosureUtil diffs our parse of the launch closure of execPath in the dyld_shared_cache at
+// cachePath against the output of Apple's dyld_closure_util (skipped when the tool is not installed)
+func CompareWithClosureUtil(t *testing.T, cachePath, execPath string) {
+	t.Helper()
+
+	tool, err := exec.LookPath("dyld_closure_util")
+	if err != nil {
+		t.Skip("dyld_closure_util not found in PATH")
+	}
+
+	out, err := exec.Command(tool, "-cache_file", cachePath, "-print_closures", execPath).Output()
+	if err != nil {
+		t.Fatalf("failed to run %s: %v", tool, err)
+	}
+	var want closureUtilSet
+	if err := json.Unmarshal(out, &want); err != nil {
+		t.Fatalf("failed to parse dyld_closure_util output: %v", err)
+	}
+
+	f, err := Open(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	pset, err := f.GetLaunchLoaderSet(execPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pset.Loaders) != len(want.Loaders) {
+		t.Fatalf("got %d loaders, dyld_closure_util has %d", len(pset.Loaders), len(want.Loaders))
+	}
+	for idx, ldr := range want.Loaders {
+		got := pset.Loaders[idx]
+		if got.Path != ldr.Path {
+			t.Errorf("loader[%d] path = %q, dyld_closure_util has %q", idx, got.Path, ldr.Path)
+		}
+		if len(got.Dependents) != len(ldr.Dependents) {
+			t.Errorf("loader[%d] (%s) has %d dependents, dyld_closure_util has %d", idx, got.Path, len(got.Dependents), len(ldr.Dependents))
+		}
+	}
+}
+
+// TestCompareWithClosureUtil runs with:
+//
+//	IPSW_TEST_DSC=/path/to/dyld_shared_cache_arm64e IPSW_TEST_EXEC=/usr/bin/true go test -tags closureutil ./pkg/dyld/
+func TestCompareWithClosureUtil(t *testing.T) {
+	cachePath := os.Getenv("IPSW_TEST_DSC")
+	execPath := os.Getenv("IPSW_TEST_EXEC")
+	if cachePath == "" || execPath == "" {
+		t.Skip("IPSW_TEST_DSC and IPSW_TEST_EXEC must be set")
+	}
+	CompareWithClosureUtil(t, cachePath, execPath)
+}
@@ -0,0 +1,106 @@
+package dyld
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// LoaderSetDiff is the difference between two PrebuiltLoaderSets (e.g. the same closure from two builds)
+type LoaderSetDiff struct {
+	AddedLoaders         []string
+	RemovedLoaders       []string
+	AddedMustBeMissing   []string
+	RemovedMustBeMissing []string
+}
+
+// DiffLoaderSets returns the loaders and must-be-missing paths that were added/removed between old and new
+func DiffLoaderSets(old, new *PrebuiltLoaderSet) *LoaderSetDiff {
+	var oldPaths, newPaths []string
+	for _, pl := range old.Loaders {
+		oldPaths = append(oldPaths, pl.Path)
+	}
+	for _, pl := range new.Loaders {
+		newPaths = append(newPaths, pl.Path)
+	}
+	diff := &LoaderSetDiff{}
+	diff.AddedLoaders, diff.RemovedLoaders = diffStrings(oldPaths, newPaths)
+	diff.AddedMustBeMissing, diff.RemovedMustBeMissing = diffStrings(old.MustBeMissingPaths, new.MustBeMissingPaths)
+	return diff
+}
+
+// diffStrings returns the sorted strings in new that are not in old (added) and in old that are not in new (removed)
+func diffStrings(old, new []string) (added, removed []string) {
+	for _, s := range new {
+		if !slices.Contains(old, s) && !slices.Contains(added, s) {
+			added = append(added, s)
+		}
+	}
+	for _, s := range old {
+		if !slices.Contains(new, s) && !slices.Contains(removed, s) {
+			removed = append(removed, s)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	return added, removed
+}
+
+// IsEmpty returns true if there are no differences
+func (d LoaderSetDiff) IsEmpty() bool {
+	return len(d.AddedLoaders) == 0 && len(d.RemovedLoaders) == 0 &&
+		len(d.AddedMustBeMissing) == 0 && len(d.RemovedMustBeMissing) == 0
+}
+
+func (d LoaderSetDiff) String() string {
+	var out string
+	if len(d.AddedLoaders) > 0 || len(d.RemovedLoaders) > 0 {
+		out += "Loaders:\n"
+		for _, path := range d.AddedLoaders {
+			out += fmt.Sprintf("  + %s\n", path)
+		}
+		for _, path := range d.RemovedLoaders {
+			out += fmt.Sprintf("  - %s\n", path)
+		}
+	}
+	if len(d.AddedMustBeMissing) > 0 || len(d.RemovedMustBeMissing) > 0 {
+		// a change here can explain why a closure became invalid (e.g. a binary moved between releases)
+		out += "MustBeMissing:\n"
+		for _, path := range d.AddedMustBeMissing {
+			out += fmt.Sprintf("  + %s (now expected to be missing)\n", path)
+		}
+		for _, path := range d.RemovedMustBeMissing {
+			out += fmt.Sprintf("  - %s (no longer expected to be missing)\n", path)
+		}
+	}
+	return out
+}
+
+// Equal returns true if both sets have the same parsed contents (header, loaders, patches, must-be-missing paths,
+// dyld cache UUID and objc/swift tables). Pointer fields (e.g. FileValidation) are compared by value and the loaders'
+// location helpers (their size and cache address) are ignored, so the same closure parsed from a file and from the
+// cache (or from different caches) is equal.
+func (pls *PrebuiltLoaderSet) Equal(other *PrebuiltLoaderSet) bool {
+	if pls == nil || other == nil {
+		return pls == other
+	}
+	if pls.PrebuiltLoaderSetHeader != other.PrebuiltLoaderSetHeader || pls.DyldCacheUUID != other.DyldCacheUUID {
+		return false
+	}
+	if !slices.EqualFunc(pls.Loaders, other.Loaders, func(a, b PrebuiltLoader) bool {
+		a.size, a.addr = 0, 0 // a and b are copies
+		b.size, b.addr = 0, 0
+		return reflect.DeepEqual(a, b) // follows pointers, so FileValidation/ObjcFixupInfo are compared by value
+	}) {
+		return false
+	}
+	if !slices.Equal(pls.Patches, other.Patches) || !slices.Equal(pls.MustBeMissingPaths, other.MustBeMissingPaths) {
+		return false
+	}
+	return reflect.DeepEqual(pls.SelectorTable, other.SelectorTable) &&
+		reflect.DeepEqual(pls.ClassTable, other.ClassTable) &&
+		reflect.DeepEqual(pls.ProtocolTable, other.ProtocolTable) &&
+		reflect.DeepEqual(pls.SwiftTypeProtocolTable, other.SwiftTypeProtocolTable) &&
+		reflect.DeepEqual(pls.SwiftMetadataProtocolTable, other.SwiftMetadataProtocolTable) &&
+		reflect.DeepEqual(pls.SwiftForeignTypeProtocolTable, other.SwiftForeignTypeProtocolTable)
+}
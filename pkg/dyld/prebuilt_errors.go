@@ -0,0 +1,89 @@
+package dyld
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MagicMismatchError is returned when a PrebuiltLoaderSet or PrebuiltLoader has an unexpected magic
+// (i.e. the data is NOT a closure or the closure is corrupt)
+type MagicMismatchError struct {
+	Expected uint32
+	Got      uint32
+	Offset   int64 // offset of the structure within the reader it was parsed from
+}
+
+func (e *MagicMismatchError) Error() string {
+	var what string
+	switch e.Expected {
+	case PrebuiltLoaderSetMagic:
+		what = "PrebuiltLoaderSet"
+	case LoaderMagic:
+		what = "prebuilt loader"
+	default:
+		what = "structure"
+	}
+	return fmt.Sprintf("invalid magic for %s at offset %#x: expected %x got %x", what, e.Offset, e.Expected, e.Got)
+}
+
+// OffsetRangeError is returned when an offset or index read from a closure is outside of its valid range
+type OffsetRangeError struct {
+	Field  string // what the offset/index refers to
+	Offset uint64
+	Limit  uint64 // the offset/index must be less than this
+}
+
+func (e *OffsetRangeError) Error() string {
+	return fmt.Sprintf("%s %d (%#x) out of range (must be less than %d)", e.Field, e.Offset, e.Offset, e.Limit)
+}
+
+// MalformedEntryError is returned when an entry of a closure's variable length table is truncated or unterminated
+type MalformedEntryError struct {
+	Field  string // what the entry is
+	Index  int    // the entry's index in its table
+	Offset int64  // offset of the entry within the set
+	Err    error
+}
+
+func (e *MalformedEntryError) Error() string {
+	return fmt.Sprintf("malformed %s[%d] at offset %#x: %v", e.Field, e.Index, e.Offset, e.Err)
+}
+
+func (e *MalformedEntryError) Unwrap() error {
+	return e.Err
+}
+
+// ExportsTrieOffsetError is returned when a loader's exports trie is NOT within one of its file backed regions
+// (GetFileOffset would return 0 and the trie would be read from the start of the file)
+type ExportsTrieOffsetError struct {
+	Path     string
+	VMOffset uint64
+	Size     uint32
+}
+
+func (e *ExportsTrieOffsetError) Error() string {
+	return fmt.Sprintf("exports trie of %s at vm offset %#x (size %#x) is NOT within a file backed region", e.Path, e.VMOffset, e.Size)
+}
+
+// CDHashMismatchError is returned when a binary on disk does NOT have the CDHash its PrebuiltLoader was built against
+type CDHashMismatchError struct {
+	Path        string
+	SliceOffset uint64   // offset of the (fat) slice that was hashed
+	Expected    string   // the CDHash stored in the closure
+	Got         []string // the CDHashes of the slice's code directories
+}
+
+func (e *CDHashMismatchError) Error() string {
+	return fmt.Sprintf("CDHash mismatch for %s (slice at %#x): expected %s got %s", e.Path, e.SliceOffset, e.Expected, strings.Join(e.Got, ", "))
+}
+
+// ErrVersionMismatch is returned when a PrebuiltLoaderSet was built by a different dyld than the one in the cache
+// (dyld ignores closures whose VersionHash does NOT match its own PREBUILTLOADER_VERSION)
+type ErrVersionMismatch struct {
+	Closure uint32 // the closure's VersionHash
+	Cache   uint32 // the VersionHash of the closures built with the cache
+}
+
+func (e *ErrVersionMismatch) Error() string {
+	return fmt.Sprintf("closure version hash %#x does NOT match the dyld_shared_cache's version hash %#x", e.Closure, e.Cache)
+}
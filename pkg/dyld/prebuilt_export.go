@@ -0,0 +1,82 @@
+package dyld
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteCSV writes one row per loader in the set to w as CSV (with a header row)
+// NOTE: f is only used to name in-cache loaders (by their cache image) and may be nil
+func (pls *PrebuiltLoaderSet) WriteCSV(w io.Writer, f *File) error {
+	order := make([]int, len(pls.Loaders))
+	for idx := range order {
+		order[idx] = idx
+	}
+	return pls.writeCSV(w, f, order)
+}
+
+// WriteCSVByBindDensity is like WriteCSV but writes the loaders sorted by bind density (heaviest first)
+func (pls *PrebuiltLoaderSet) WriteCSVByBindDensity(w io.Writer, f *File) error {
+	var order []int
+	for _, stat := range pls.BindDensity() {
+		order = append(order, stat.Index)
+	}
+	return pls.writeCSV(w, f, order)
+}
+
+// hasSwift returns the has_swift CSV column from the closure's objc fixups (dyld records if any of the loader's
+// classes are stable ABI Swift classes; the objc image info's Swift version is in the binary, not the closure)
+func hasSwift(pl *PrebuiltLoader) string {
+	return fmt.Sprintf("%t", pl.ObjcFixupInfo != nil && pl.ObjcFixupInfo.Flags().Has(ObjCHasClassStableSwiftFixups))
+}
+
+// writeCSV writes the loaders at the given indices (in order) as CSV
+func (pls *PrebuiltLoaderSet) writeCSV(w io.Writer, f *File, order []int) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{
+		"path",
+		"flags",
+		"regions",
+		"dependents",
+		"weak_dependents",
+		"reexport_dependents",
+		"upward_dependents",
+		"binds",
+		"overrides",
+		"has_objc",
+		"has_swift",
+		"vm_size",
+		"binds_per_kb",
+	}); err != nil {
+		return err
+	}
+	for _, idx := range order {
+		pl := &pls.Loaders[idx]
+		kinds := pl.DependentKindCounts()
+		path := pl.Path
+		if len(path) == 0 && f != nil && !pl.Ref.IsApp() && int(pl.Ref.Index()) < len(f.Images) {
+			path = f.Images[pl.Ref.Index()].Name
+		}
+		if err := cw.Write([]string{
+			path,
+			strings.Join(pl.Loader.flags(), "|"),
+			fmt.Sprintf("%d", len(pl.Regions)),
+			fmt.Sprintf("%d", len(pl.Dependents)),
+			fmt.Sprintf("%d", kinds[KindWeakLink]),
+			fmt.Sprintf("%d", kinds[KindReexport]),
+			fmt.Sprintf("%d", kinds[KindUpward]),
+			fmt.Sprintf("%d", len(pl.BindTargets)),
+			fmt.Sprintf("%d", len(pl.OverrideBindTargets)),
+			fmt.Sprintf("%t", pl.HasObjC()),
+			hasSwift(pl),
+			fmt.Sprintf("%d", pl.VmSize),
+			fmt.Sprintf("%.2f", pl.bindsPerKB()),
+		}); err != nil {
+			return fmt.Errorf("failed to write CSV row for %s: %w", path, err)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,168 @@
+package dyld
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/blacktop/go-macho/pkg/trie"
+)
+
+// bindTargetResolver resolves BindTargetRefs to symbols, caching each cache image's exports
+type bindTargetResolver struct {
+	f       *File
+	exports map[uint16]map[uint64]trie.TrieExport
+}
+
+func newBindTargetResolver(f *File) *bindTargetResolver {
+	return &bindTargetResolver{
+		f:       f,
+		exports: make(map[uint16]map[uint64]trie.TrieExport),
+	}
+}
+
+// ResolveBindTarget resolves a bind target to the symbol it points at using the target image's exports trie
+// NOTE: this walks the exports trie of the target image (use a single resolver when resolving many targets)
+func (f *File) ResolveBindTarget(bt BindTargetRef) (*ResolvedSymbol, error) {
+	return newBindTargetResolver(f).resolve(bt)
+}
+
+// ClosureCacheImports returns a map of cache dylib name to the sorted (deduplicated) symbol names that
+// the loaders in the launch closure of execPath bind to
+func (f *File) ClosureCacheImports(execPath string) (map[string][]string, error) {
+	pset, err := f.GetLaunchLoaderSet(execPath)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newBindTargetResolver(f)
+	seen := make(map[string]map[string]bool)
+	for _, pl := range pset.Loaders {
+		for _, bt := range pl.BindTargets {
+			if bt.IsAbsolute() || bt.LoaderRef().IsApp() || bt.LoaderRef().IsMissingWeakImage() {
+				continue
+			}
+			rs, err := r.resolve(bt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve bind target of %s: %w", pl.Path, err)
+			}
+			if len(rs.TargetSymbolName) == 0 {
+				continue
+			}
+			if _, ok := seen[rs.TargetLoaderPath]; !ok {
+				seen[rs.TargetLoaderPath] = make(map[string]bool)
+			}
+			seen[rs.TargetLoaderPath][rs.TargetSymbolName] = true
+		}
+	}
+
+	imports := make(map[string][]string, len(seen))
+	for dylib, syms := range seen {
+		for sym := range syms {
+			imports[dylib] = append(imports[dylib], sym)
+		}
+		slices.Sort(imports[dylib])
+	}
+
+	return imports, nil
+}
+
+// DylibPatchExportName returns the name of the cache export that the idx'th dylib patch of the root loader pl replaces
+// NOTE: a root's DylibPatches are indexed by the patchable exports of the cache dylib it overrides (the cache image
+// at the root's path), so this parses the cache's patch info the first time it is needed
+func (f *File) DylibPatchExportName(pl *PrebuiltLoader, idx int) (string, error) {
+	var img *CacheImage
+	for _, i := range f.Images {
+		if i.Name == pl.Path || (len(pl.AltPath) > 0 && i.Name == pl.AltPath) {
+			img = i
+			break
+		}
+	}
+	if img == nil {
+		return "", fmt.Errorf("%s does not override a cache dylib", pl.Path)
+	}
+	if len(img.PatchableExports) == 0 && f.PatchInfoVersion == 0 {
+		if err := f.ParsePatchInfo(); err != nil {
+			return "", err
+		}
+	}
+	if idx < 0 || idx >= len(img.PatchableExports) {
+		return "", &OffsetRangeError{Field: "dylib patch index", Offset: uint64(idx), Limit: uint64(len(img.PatchableExports))}
+	}
+	return img.PatchableExports[idx].GetName(), nil
+}
+
+// WeakMissing is a weak bind target of a loader whose image is expected to be missing at runtime
+// NOTE: there is no symbol name; the closure only records the bind target's offset (which is meaningless for a
+// missing image) and the name only exists in the importing binary's own binds
+type WeakMissing struct {
+	LoaderPath string
+	BindIndex  int    // index into the loader's BindTargets
+	Offset     uint64 // the bind target's offset (addend)
+}
+
+func (wm WeakMissing) String() string {
+	return fmt.Sprintf("%s: bind-target[%d] %#x (missing weak image)", wm.LoaderPath, wm.BindIndex, wm.Offset)
+}
+
+// ClosureWeakMissing returns the bind targets of the launch closure of execPath that point at missing weak images
+func (f *File) ClosureWeakMissing(execPath string) ([]WeakMissing, error) {
+	pset, err := f.GetLaunchLoaderSet(execPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []WeakMissing
+	for _, pl := range pset.Loaders {
+		for idx, bt := range pl.BindTargets {
+			if bt.IsAbsolute() || !bt.LoaderRef().IsMissingWeakImage() {
+				continue
+			}
+			missing = append(missing, WeakMissing{
+				LoaderPath: pl.Path,
+				BindIndex:  idx,
+				Offset:     bt.Offset(),
+			})
+		}
+	}
+
+	return missing, nil
+}
+
+func (r *bindTargetResolver) resolve(bt BindTargetRef) (*ResolvedSymbol, error) {
+	if bt.IsAbsolute() {
+		return &ResolvedSymbol{
+			TargetRuntimeOffset: bt.Offset(),
+			Kind:                RSKindBindAbsolute,
+		}, nil
+	}
+	rs := &ResolvedSymbol{
+		TargetRuntimeOffset: bt.Offset(),
+		Kind:                RSKindBindToImage,
+	}
+	ref := bt.LoaderRef()
+	if ref.IsApp() || ref.IsMissingWeakImage() {
+		return rs, nil // not in the cache (nothing to resolve against)
+	}
+	if int(ref.Index()) >= len(r.f.Images) {
+		return nil, &OffsetRangeError{Field: "bind target image index", Offset: uint64(ref.Index()), Limit: uint64(len(r.f.Images))}
+	}
+	img := r.f.Images[ref.Index()]
+	rs.TargetLoaderPath = img.Name
+	exports, ok := r.exports[ref.Index()]
+	if !ok {
+		syms, err := r.f.GetExportTrieSymbols(img)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get exports for %s: %w", img.Name, err)
+		}
+		exports = make(map[uint64]trie.TrieExport, len(syms))
+		for _, sym := range syms {
+			exports[sym.Address-img.LoadAddress] = sym
+		}
+		r.exports[ref.Index()] = exports
+	}
+	if sym, ok := exports[bt.Offset()]; ok {
+		rs.TargetSymbolName = sym.Name
+		rs.IsWeakDef = sym.Flags.WeakDefinition()
+	}
+	return rs, nil
+}
@@ -0,0 +1,150 @@
+package dyld
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SQLiteDriverName is the database/sql driver ExportClosuresToSQLite opens its database with
+// NOTE: pkg/dyld does NOT link a sqlite driver; the caller must register one (e.g. import _ "github.com/glebarez/go-sqlite")
+var SQLiteDriverName = "sqlite"
+
+// closureTables is the schema ExportClosuresToSQLite creates (every row is keyed by the closure's exec path and the loader's index in it)
+var closureTables = []string{
+	`CREATE TABLE IF NOT EXISTS loaders (exec_path TEXT NOT NULL, loader_index INTEGER NOT NULL, path TEXT, alt_path TEXT, flags TEXT, in_cache BOOLEAN, is_app BOOLEAN, vm_size INTEGER, has_objc BOOLEAN, cd_hash TEXT)`,
+	`CREATE INDEX IF NOT EXISTS idx_loaders_exec_path ON loaders (exec_path)`,
+	`CREATE INDEX IF NOT EXISTS idx_loaders_path ON loaders (path)`,
+	`CREATE TABLE IF NOT EXISTS dependents (exec_path TEXT NOT NULL, loader_index INTEGER NOT NULL, path TEXT, kind TEXT)`,
+	`CREATE INDEX IF NOT EXISTS idx_dependents_exec_path ON dependents (exec_path)`,
+	`CREATE INDEX IF NOT EXISTS idx_dependents_path ON dependents (path)`,
+	// target is the target loader's path ("" if absolute or unresolved); sqlite integers are signed (bind target offsets can be negative)
+	`CREATE TABLE IF NOT EXISTS bind_targets (exec_path TEXT NOT NULL, loader_index INTEGER NOT NULL, bind_index INTEGER NOT NULL, is_absolute BOOLEAN, target TEXT, offset INTEGER)`,
+	`CREATE INDEX IF NOT EXISTS idx_bind_targets_exec_path ON bind_targets (exec_path)`,
+	`CREATE INDEX IF NOT EXISTS idx_bind_targets_target ON bind_targets (target)`,
+	`CREATE TABLE IF NOT EXISTS regions (exec_path TEXT NOT NULL, loader_index INTEGER NOT NULL, region_index INTEGER NOT NULL, vm_offset INTEGER, file_offset INTEGER, file_size INTEGER, perms TEXT, zero_fill BOOLEAN)`,
+	`CREATE INDEX IF NOT EXISTS idx_regions_exec_path ON regions (exec_path)`,
+	// dylib is the overridden cache dylib
+	`CREATE TABLE IF NOT EXISTS patches (exec_path TEXT NOT NULL, dylib TEXT, dylib_vm_offset INTEGER, replace_loader TEXT, replace_offset INTEGER)`,
+	`CREATE INDEX IF NOT EXISTS idx_patches_exec_path ON patches (exec_path)`,
+	`CREATE INDEX IF NOT EXISTS idx_patches_dylib ON patches (dylib)`,
+}
+
+// closureStmts are the prepared insert statements of an export (prepared once and reused for every row)
+type closureStmts struct {
+	loader    *sql.Stmt
+	dependent *sql.Stmt
+	bind      *sql.Stmt
+	region    *sql.Stmt
+	patch     *sql.Stmt
+}
+
+func prepareClosureStmts(tx *sql.Tx) (*closureStmts, error) {
+	var (
+		s   closureStmts
+		err error
+	)
+	for _, p := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.loader, `INSERT INTO loaders (exec_path, loader_index, path, alt_path, flags, in_cache, is_app, vm_size, has_objc, cd_hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`},
+		{&s.dependent, `INSERT INTO dependents (exec_path, loader_index, path, kind) VALUES (?, ?, ?, ?)`},
+		{&s.bind, `INSERT INTO bind_targets (exec_path, loader_index, bind_index, is_absolute, target, offset) VALUES (?, ?, ?, ?, ?, ?)`},
+		{&s.region, `INSERT INTO regions (exec_path, loader_index, region_index, vm_offset, file_offset, file_size, perms, zero_fill) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`},
+		{&s.patch, `INSERT INTO patches (exec_path, dylib, dylib_vm_offset, replace_loader, replace_offset) VALUES (?, ?, ?, ?, ?)`},
+	} {
+		if *p.stmt, err = tx.Prepare(p.query); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to prepare %q: %w", p.query, err)
+		}
+	}
+	return &s, nil
+}
+
+func (s *closureStmts) Close() {
+	for _, stmt := range []*sql.Stmt{s.loader, s.dependent, s.bind, s.region, s.patch} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+// ExportClosuresToSQLite writes every launch closure in the cache to a sqlite database at path with the tables:
+// loaders, dependents, bind_targets, regions and patches (the set's cache patches)
+// NOTE: the whole export is a single transaction so a failed export leaves the database unchanged.
+// The database is opened with the SQLiteDriverName database/sql driver which the caller must register.
+func (f *File) ExportClosuresToSQLite(path string) error {
+	db, err := sql.Open(SQLiteDriverName, path)
+	if err != nil {
+		return fmt.Errorf("failed to open sqlite database %s: %w", path, err)
+	}
+	defer db.Close()
+	return f.exportClosures(db)
+}
+
+func (f *File) exportClosures(db *sql.DB) error {
+	for _, stmt := range closureTables {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create closure tables: %w", err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	stmts, err := prepareClosureStmts(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmts.Close()
+
+	if err := f.forEachLaunchLoaderSet(func(execPath string, pset *PrebuiltLoaderSet) error {
+		if err := pset.insertSQLite(stmts, f, execPath); err != nil {
+			return fmt.Errorf("failed to export closure of %s: %w", execPath, err)
+		}
+		return nil
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (pls *PrebuiltLoaderSet) insertSQLite(s *closureStmts, f *File, execPath string) error {
+	for lidx, pl := range pls.Loaders {
+		var cdHash string
+		if pl.FileValidation != nil && pl.FileValidation.CheckCDHash {
+			cdHash = pl.FileValidation.CDHashString()
+		}
+		if _, err := s.loader.Exec(execPath, lidx, pl.Path, pl.AltPath, strings.Join(pl.Loader.flags(), "|"),
+			pl.DylibInDyldCache(), pl.Ref.IsApp(), pl.VmSize, pl.HasObjC(), cdHash); err != nil {
+			return err
+		}
+		for _, dep := range pl.Dependents {
+			if _, err := s.dependent.Exec(execPath, lidx, dep.Name, dep.Kind.String()); err != nil {
+				return err
+			}
+		}
+		for bidx, bt := range pl.BindTargets {
+			var target string
+			if !bt.IsAbsolute() {
+				target, _ = pls.loaderRefName(f, bt.LoaderRef())
+			}
+			if _, err := s.bind.Exec(execPath, lidx, bidx, bt.IsAbsolute(), target, int64(bt.Offset())); err != nil {
+				return err
+			}
+		}
+		for ridx, r := range pl.Regions {
+			if _, err := s.region.Exec(execPath, lidx, ridx, int64(r.VMOffset()), r.FileOffset, r.FileSize, r.Perms().String(), r.IsZeroFill()); err != nil {
+				return err
+			}
+		}
+	}
+	return pls.ForEachCachePatch(f, func(rp ResolvedCachePatch) error {
+		_, err := s.patch.Exec(execPath, rp.Dylib, rp.DylibVMOffset, rp.ReplaceLoader, int64(rp.ReplaceOffset))
+		return err
+	})
+}
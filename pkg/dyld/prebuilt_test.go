@@ -0,0 +1,2567 @@
+package dyld
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/blacktop/go-macho/pkg/trie"
+	"github.com/blacktop/go-macho/types"
+)
+
+func TestFileValidationCDHashString(t *testing.T) {
+	// the stored CDHash must be printed as-is (not hashed again)
+	fv := fileValidation{
+		CDHash: [20]byte{
+			0x6b, 0x1a, 0x3f, 0x2e, 0x81, 0x4c, 0x0d, 0x9a, 0x57, 0xe2,
+			0x10, 0xb4, 0xc3, 0x8f, 0x66, 0x05, 0xde, 0x21, 0x97, 0xaa,
+		},
+		CheckCDHash: true,
+	}
+	want := "6b1a3f2e814c0d9a57e210b4c38f6605de2197aa"
+	if got := fv.CDHashString(); got != want {
+		t.Errorf("CDHashString() = %s, want %s", got, want)
+	}
+}
+
+func TestObjCBinaryInfoLayout(t *testing.T) {
+	if got := binary.Size(ObjCBinaryInfo{}); got != objCBinaryInfoSize {
+		t.Errorf("binary.Size(ObjCBinaryInfo) = %d, want %d", got, objCBinaryInfoSize)
+	}
+	// the in-memory struct is padded to 8-byte alignment
+	if got := unsafe.Sizeof(ObjCBinaryInfo{}); got != 80 {
+		t.Errorf("unsafe.Sizeof(ObjCBinaryInfo) = %d, want 80", got)
+	}
+
+	dat := make([]byte, objCBinaryInfoSize)
+	binary.LittleEndian.PutUint64(dat[0:], 0x1000)
+	binary.LittleEndian.PutUint32(dat[52:], 3)
+	dat[56] = 1
+	dat[62] = 1
+	binary.LittleEndian.PutUint32(dat[72:], 7)
+	ofi, err := readObjCBinaryInfo(bytes.NewReader(dat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ofi.ImageInfoRuntimeOffset != 0x1000 || ofi.ProtocolListCount != 3 || ofi.SelectorReferencesFixupsCount != 7 {
+		t.Errorf("readObjCBinaryInfo() decoded wrong fields: %#v", ofi)
+	}
+	if !ofi.HasClassStableSwiftFixups || !ofi.HasProtocolMethodListsToUnique || ofi.HasClassMethodListsToUnique {
+		t.Errorf("readObjCBinaryInfo() decoded wrong flags: %#v", ofi)
+	}
+}
+
+func TestPrebuiltLoaderSetContentHash(t *testing.T) {
+	newSet := func() *PrebuiltLoaderSet {
+		return &PrebuiltLoaderSet{
+			Loaders: []PrebuiltLoader{{
+				Path:          "/usr/lib/libfoo.dylib",
+				DependentRefs: []LoaderRef{1, 2},
+				Dependents:    []dependent{{Kind: KindNormal}, {Kind: KindWeakLink}},
+				FileValidation: &fileValidation{
+					CDHash: [20]byte{0xde, 0xad},
+				},
+				Regions:     []Region{{Info: 0x1000, FileOffset: 0x1000, FileSize: 0x4000}},
+				BindTargets: []BindTargetRef{0x1234},
+			}},
+			MustBeMissingPaths: []string{"/usr/lib/libbar.dylib"},
+		}
+	}
+	a, b := newSet(), newSet()
+	if a.ContentHash() != b.ContentHash() {
+		t.Fatal("ContentHash() differs for identical sets")
+	}
+	b.Loaders[0].Regions[0].FileSize = 0x8000
+	if a.ContentHash() == b.ContentHash() {
+		t.Error("ContentHash() did not change when a region changed")
+	}
+	b = newSet()
+	b.Loaders[0].FileValidation.CDHash[0] = 0
+	if a.ContentHash() == b.ContentHash() {
+		t.Error("ContentHash() did not change when the file validation changed")
+	}
+}
+
+func TestParsePrebuiltLoaderSetMixed(t *testing.T) {
+	hdrSize := uint32(binary.Size(PrebuiltLoaderSetHeader{}))
+	ldrSize := uint32(binary.Size(prebuiltLoaderHeader{}))
+
+	buf := new(bytes.Buffer)
+	loadersOff := hdrSize
+	ldr0Off := loadersOff + 8
+	ldr1Off := ldr0Off + ldrSize + 0x20
+	binary.Write(buf, binary.LittleEndian, PrebuiltLoaderSetHeader{
+		Magic:              PrebuiltLoaderSetMagic,
+		LoadersArrayCount:  2,
+		LoadersArrayOffset: loadersOff,
+	})
+	binary.Write(buf, binary.LittleEndian, []uint32{ldr0Off, ldr1Off})
+	binary.Write(buf, binary.LittleEndian, prebuiltLoaderHeader{
+		Loader:      Loader{Magic: LoaderMagic, Info: 1 /* isPrebuilt */, Ref: LoaderRef(0x8000)},
+		PathOffset:  uint16(ldrSize),
+		IndexOfTwin: NoUnzipperedTwin,
+	})
+	path := make([]byte, 0x20)
+	copy(path, "/usr/bin/foo")
+	buf.Write(path)
+	binary.Write(buf, binary.LittleEndian, prebuiltLoaderHeader{
+		Loader: Loader{Magic: LoaderMagic, Info: 0 /* JIT */, Ref: LoaderRef(0x8001)},
+	})
+
+	pset, err := ParseLoaderSetAt(bytes.NewReader(buf.Bytes()), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pset.Loaders) != 2 {
+		t.Fatalf("got %d loaders, want 2", len(pset.Loaders))
+	}
+	if pset.Loaders[0].Path != "/usr/bin/foo" || !pset.Loaders[0].IsPrebuilt() {
+		t.Errorf("loader[0] = %q (prebuilt=%t), want prebuilt /usr/bin/foo", pset.Loaders[0].Path, pset.Loaders[0].IsPrebuilt())
+	}
+	if pset.Loaders[1].IsPrebuilt() || pset.Loaders[1].Ref.Index() != 1 {
+		t.Errorf("loader[1] should be a JIT placeholder with ref index 1, got %s", pset.Loaders[1].Loader)
+	}
+
+	f := &File{ByteOrder: binary.LittleEndian}
+	if _, err := f.parsePrebuiltLoader(io.NewSectionReader(bytes.NewReader(buf.Bytes()), int64(ldr1Off), int64(ldrSize)), nil); !errors.Is(err, ErrJITLoader) {
+		t.Errorf("parsePrebuiltLoader() error = %v, want ErrJITLoader", err)
+	}
+}
+
+func TestPrebuiltLoaderTranslateVMOffset(t *testing.T) {
+	const (
+		perms    = uint64(3) << 59 // rw-
+		zeroFill = uint64(1) << 62
+	)
+	pl := PrebuiltLoader{
+		prebuiltLoaderHeader: prebuiltLoaderHeader{VmSize: 0x10000, IndexOfTwin: NoUnzipperedTwin},
+		Regions: []Region{
+			{Info: 0x0000 | perms, FileOffset: 0x0000, FileSize: 0x4000},
+			{Info: 0x4000 | perms, FileOffset: 0x4000, FileSize: 0x1000}, // VM extent runs to 0x8000
+			{Info: 0x8000 | perms | zeroFill, FileOffset: 0, FileSize: 0},
+		},
+	}
+	tests := []struct {
+		vmoff    uint64
+		off      uint64
+		zeroFill bool
+		ok       bool
+	}{
+		{0x0010, 0x0010, false, true},
+		{0x4800, 0x4800, false, true},
+		{0x6000, 0, true, true}, // VM size exceeds file size
+		{0x9000, 0, true, true}, // zero-fill region up to the loader's VM size
+		{0x10000, 0, false, false},
+	}
+	for _, tt := range tests {
+		off, zf, ok := pl.TranslateVMOffset(tt.vmoff)
+		if off != tt.off || zf != tt.zeroFill || ok != tt.ok {
+			t.Errorf("TranslateVMOffset(%#x) = (%#x, %t, %t), want (%#x, %t, %t)", tt.vmoff, off, zf, ok, tt.off, tt.zeroFill, tt.ok)
+		}
+	}
+}
+
+func TestPrebuiltLoaderExportsTrieFileOffset(t *testing.T) {
+	const perms = uint64(1) << 59 // r--
+	pl := PrebuiltLoader{
+		prebuiltLoaderHeader: prebuiltLoaderHeader{VmSize: 0x10000, IndexOfTwin: NoUnzipperedTwin},
+		Path:                 "/usr/lib/libfoo.dylib",
+		Regions: []Region{
+			{Info: 0x0000 | perms, FileOffset: 0x0000, FileSize: 0x4000},
+			{Info: 0x8000 | perms, FileOffset: 0x4000, FileSize: 0x2000},
+		},
+	}
+	data := make([]byte, 0x6000)
+	copy(data[0x4100:], "trie")
+
+	pl.ExportsTrieLoaderOffset, pl.ExportsTrieLoaderSize = 0x8100, 4
+	if off, err := pl.ExportsTrieFileOffset(); err != nil || off != 0x4100 {
+		t.Fatalf("ExportsTrieFileOffset() = (%#x, %v), want (0x4100, nil)", off, err)
+	}
+	if trie, err := pl.ReadExportsTrie(bytes.NewReader(data), int64(len(data))); err != nil || string(trie) != "trie" {
+		t.Fatalf("ReadExportsTrie() = (%q, %v), want (\"trie\", nil)", trie, err)
+	}
+
+	for _, tt := range []struct {
+		vmoff uint64
+		size  uint32
+	}{
+		{0x20000, 4}, // beyond every region
+		{0x5000, 4},  // in the gap between the regions (VM size exceeds file size)
+		{0x9ffe, 4},  // runs past the end of the region's file data
+	} {
+		pl.ExportsTrieLoaderOffset, pl.ExportsTrieLoaderSize = tt.vmoff, tt.size
+		var oerr *ExportsTrieOffsetError
+		if _, err := pl.ExportsTrieFileOffset(); !errors.As(err, &oerr) || oerr.VMOffset != tt.vmoff {
+			t.Errorf("ExportsTrieFileOffset() at %#x error = %v, want ExportsTrieOffsetError", tt.vmoff, err)
+		}
+		if _, err := pl.ReadExportsTrie(bytes.NewReader(data), int64(len(data))); !errors.As(err, &oerr) {
+			t.Errorf("ReadExportsTrie() at %#x error = %v, want ExportsTrieOffsetError", tt.vmoff, err)
+		}
+	}
+}
+
+func TestPrebuiltLoaderReadObjCImageInfo(t *testing.T) {
+	const perms = uint64(3) << 59 // rw-
+	pl := PrebuiltLoader{
+		prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Info: 1 << 2, Ref: NewLoaderRef(0, true)}, VmSize: 0x8000, IndexOfTwin: NoUnzipperedTwin}, // hasObjC
+		Path:                 "/Applications/Foo.app/Foo",
+		Regions: []Region{
+			{Info: 0x0000 | perms, FileOffset: 0x0000, FileSize: 0x4000},
+			{Info: 0x4000 | perms, FileOffset: 0x4000, FileSize: 0x1000},
+		},
+		ObjcFixupInfo: &ObjCBinaryInfo{ImageInfoRuntimeOffset: 0x4010},
+	}
+	data := make([]byte, 0x5000)
+	binary.LittleEndian.PutUint32(data[0x4014:], 7<<8) // flags: swift 5 (unstable ABI version 7)
+
+	info, err := pl.ReadObjCImageInfo(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.HasSwift() {
+		t.Errorf("ReadObjCImageInfo() = %+v, want swift", info)
+	}
+	// the image info of an on-disk binary can NOT be read from the cache
+	if _, err := (&File{}).ObjCImageInfo(&pl); !errors.Is(err, ErrLoaderNotInCache) {
+		t.Errorf("ObjCImageInfo() error = %v, want ErrLoaderNotInCache", err)
+	}
+
+	pl.ObjcFixupInfo.ImageInfoRuntimeOffset = 0x6000 // past the end of the regions
+	if _, err := pl.ReadObjCImageInfo(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("ReadObjCImageInfo() error = nil for an image info outside of the regions")
+	}
+}
+
+func TestNewLoaderRef(t *testing.T) {
+	for i := 0; i < 0x8000; i++ {
+		for _, app := range []bool{false, true} {
+			ref := NewLoaderRef(uint16(i), app)
+			if ref.Index() != uint16(i) || ref.IsApp() != app {
+				t.Fatalf("NewLoaderRef(%d, %t) = (index: %d, app: %t)", i, app, ref.Index(), ref.IsApp())
+			}
+			if ref.IsMissingWeakImage() != (i == 0x7fff && !app) {
+				t.Fatalf("NewLoaderRef(%d, %t).IsMissingWeakImage() = %t", i, app, ref.IsMissingWeakImage())
+			}
+		}
+	}
+	if ref := NewMissingWeakImageRef(); !ref.IsMissingWeakImage() {
+		t.Errorf("NewMissingWeakImageRef() = %s, want missing weak image", ref)
+	}
+}
+
+func TestNewBindTargetRef(t *testing.T) {
+	offsets := []int64{
+		0,
+		1,
+		-1,
+		0x1234,
+		-0x1234,
+		1<<38 - 1, // largest positive low39
+		-(1 << 38),
+		0x7f00000000001234, // high8 carries the top byte
+		-0x7f00000000001234,
+		0x0100003fffffffff,
+		-0x0100004000000000,
+	}
+	for i := 0; i < 1000; i++ { // sweep low39 and high8 independently
+		low := int64(i)*0x1fffffff - 0x4000000000
+		offsets = append(offsets, int64(uint64(i&0xff)<<56)|(low&0x00ffffffffffffff))
+	}
+	for _, off := range offsets {
+		if uint64(off)&0x00ffff8000000000 != 0 && uint64(off)&0x00ffff8000000000 != 0x00ffff8000000000 {
+			continue // not encodable (bits 39-55 must sign extend bit 38)
+		}
+		for _, ref := range []LoaderRef{NewLoaderRef(0, false), NewLoaderRef(0x1234, true), NewMissingWeakImageRef()} {
+			bt := NewBindTargetRef(ref, off)
+			if bt.IsAbsolute() {
+				t.Fatalf("NewBindTargetRef(%s, %#x) is absolute", ref, off)
+			}
+			if bt.LoaderRef() != ref {
+				t.Fatalf("NewBindTargetRef(%s, %#x).LoaderRef() = %s", ref, off, bt.LoaderRef())
+			}
+			if got := bt.Offset(); got != uint64(off) {
+				t.Fatalf("NewBindTargetRef(%s, %#x).Offset() = %#x", ref, off, got)
+			}
+		}
+	}
+}
+
+func TestNewAbsoluteBindTargetRef(t *testing.T) {
+	for _, val := range []uint64{0, 1, 0x1234, 0x3fffffffffffffff, 0xc000000000000000, 0xffffffffffffffff} {
+		bt := NewAbsoluteBindTargetRef(val)
+		if !bt.IsAbsolute() {
+			t.Fatalf("NewAbsoluteBindTargetRef(%#x) is not absolute", val)
+		}
+		if got := bt.Offset(); got != val {
+			t.Fatalf("NewAbsoluteBindTargetRef(%#x).Offset() = %#x", val, got)
+		}
+	}
+}
+
+func TestResolvedSymbolString(t *testing.T) {
+	rs := ResolvedSymbol{
+		TargetLoaderPath:    "/usr/lib/libSystem.B.dylib",
+		TargetSymbolName:    "_foo",
+		TargetRuntimeOffset: 0x1234,
+		Kind:                RSKindBindToImage,
+		IsCode:              true,
+		IsWeakDef:           true,
+	}
+	if got, want := rs.String(), "bind to image _foo (weak-def, code) -> /usr/lib/libSystem.B.dylib+0x1234"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	abs := ResolvedSymbol{TargetRuntimeOffset: 0x1000, Kind: RSKindBindAbsolute}
+	if got, want := abs.String(), "bind absolute 0x1000"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestWalkProgramTrie(t *testing.T) {
+	// each node is placed at a fixed 0x20 aligned offset (all values fit in a single byte uleb128)
+	nodes := [][]byte{
+		0x00: {0x00, 0x02, '/', 'u', 's', 'r', '/', 'b', 'i', 'n', '/', 0x00, 0x20, '/', 'b', 'i', 'n', '/', 'l', 's', 0x00, 0x60},
+		0x20: {0x00, 0x02, 't', 'r', 'u', 'e', 0x00, 0x40, 'f', 'a', 'l', 's', 'e', 0x00, 0x70},
+		0x40: {0x01, 0x10, 0x00},
+		0x60: {0x01, 0x30, 0x00},
+		0x70: {0x02, 0x20, 0xff, 0x00}, // terminal info with trailing data that must be skipped
+	}
+	var trie []byte
+	for off, node := range nodes {
+		if node == nil {
+			continue
+		}
+		trie = append(trie, make([]byte, off-len(trie))...)
+		trie = append(trie, node...)
+	}
+
+	got := make(map[string]uint64)
+	var order []string
+	if err := walkProgramTrie(bytes.NewReader(trie), int64(len(trie)), func(execPath string, poolOffset uint64) error {
+		got[execPath] = poolOffset
+		order = append(order, execPath)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint64{"/usr/bin/true": 0x10, "/usr/bin/false": 0x20, "/bin/ls": 0x30}
+	if len(got) != len(want) {
+		t.Fatalf("walkProgramTrie() found %v, want %v", got, want)
+	}
+	for path, off := range want {
+		if got[path] != off {
+			t.Errorf("walkProgramTrie() %s = %#x, want %#x", path, got[path], off)
+		}
+	}
+	if order[0] != "/usr/bin/true" || order[2] != "/bin/ls" {
+		t.Errorf("walkProgramTrie() visited %v out of trie order", order)
+	}
+
+	for path, off := range want {
+		if got, err := lookupProgramTrie(bytes.NewReader(trie), int64(len(trie)), path); err != nil || got != off {
+			t.Errorf("lookupProgramTrie(%s) = %#x, %v, want %#x", path, got, err, off)
+		}
+	}
+	for _, path := range []string{"/usr/bin/", "/usr/bin/cat", "/bin/lsof", ""} {
+		if got, err := lookupProgramTrie(bytes.NewReader(trie), int64(len(trie)), path); err == nil {
+			t.Errorf("lookupProgramTrie(%q) = %#x, want an error", path, got)
+		}
+	}
+
+	// a child pointing back at the root must not loop forever
+	loop := []byte{0x00, 0x01, 'a', 0x00, 0x00}
+	if err := walkProgramTrie(bytes.NewReader(loop), int64(len(loop)), func(string, uint64) error { return nil }); err == nil {
+		t.Error("walkProgramTrie() on a looping trie returned no error")
+	}
+	if _, err := lookupProgramTrie(bytes.NewReader(loop), int64(len(loop)), "aaaa"); err == nil {
+		t.Error("lookupProgramTrie() on a looping trie returned no error")
+	}
+}
+
+// BenchmarkProgramTrieLookup compares reading the whole ProgramTrie into memory to look up a single executable
+// (what getLaunchLoaderSetAddr used to do) with lookupProgramTrie's node by node walk (compare the B/op columns)
+func BenchmarkProgramTrieLookup(b *testing.B) {
+	const (
+		groups    = 80  // 80 × 250 apps is a ~1.5MB trie (the size of an iOS cache's ProgramTrie)
+		perGroup  = 250 // child counts are a single byte
+		groupSize = 3 + 1 + 4
+	)
+	// fixed 4 byte uleb128s so node offsets can be computed up front
+	uleb4 := func(v int) []byte {
+		return []byte{byte(v) | 0x80, byte(v>>7) | 0x80, byte(v>>14) | 0x80, byte(v >> 21)}
+	}
+	path := func(i int) string {
+		return fmt.Sprintf("/private/var/containers/Bundle/Application/%05d/App.app/App", i)
+	}
+
+	// root: a "/" edge to a node with a child per group, each with a child per app (a terminal leaf)
+	programTrie := append([]byte{0x00, 0x01, '/', 0x00}, uleb4(8)...)
+	programTrie = append(programTrie, 0x00, groups)
+	groupOff := len(programTrie) + groups*groupSize
+	for g := 0; g < groups; g++ {
+		programTrie = append(append(programTrie, fmt.Sprintf("%03d\x00", g)...), uleb4(groupOff)...)
+		groupOff += 2 + 6*perGroup // child count and the leaves
+		for i := 0; i < perGroup; i++ {
+			groupOff += len(path(g*perGroup+i)) + 1 + 4
+		}
+	}
+	for g := 0; g < groups; g++ {
+		programTrie = append(programTrie, 0x00, perGroup)
+		leafOff := len(programTrie)
+		for i := 0; i < perGroup; i++ {
+			leafOff += len(path(g*perGroup+i)) + 1 + 4
+		}
+		for i := 0; i < perGroup; i++ {
+			programTrie = append(append(programTrie, path(g*perGroup+i)+"\x00"...), uleb4(leafOff+i*6)...)
+		}
+		for i := 0; i < perGroup; i++ {
+			programTrie = append(append(append(programTrie, 0x04), uleb4(g*perGroup+i)...), 0x00) // terminal info: the pool offset
+		}
+	}
+	const want = groups*perGroup - 1
+	last := fmt.Sprintf("/%03d%s", groups-1, path(want))
+	r := bytes.NewReader(programTrie)
+
+	b.Run("ReadAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			dat := make([]byte, len(programTrie))
+			if _, err := r.ReadAt(dat, 0); err != nil {
+				b.Fatal(err)
+			}
+			tr := bytes.NewReader(dat)
+			if _, err := trie.WalkTrie(tr, last); err != nil {
+				b.Fatal(err)
+			}
+			if got, err := trie.ReadUleb128(tr); err != nil || got != want {
+				b.Fatalf("trie.WalkTrie() = %#x, %v, want %#x", got, err, want)
+			}
+		}
+	})
+	b.Run("Streaming", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if got, err := lookupProgramTrie(r, int64(len(programTrie)), last); err != nil || got != want {
+				b.Fatalf("lookupProgramTrie() = %#x, %v, want %#x", got, err, want)
+			}
+		}
+	})
+}
+
+func TestParsePrebuiltLoaderAllNormalDependents(t *testing.T) {
+	ldrSize := uint16(binary.Size(prebuiltLoaderHeader{}))
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, prebuiltLoaderHeader{
+		Loader:                         Loader{Magic: LoaderMagic, Info: 1 /* isPrebuilt */},
+		DependentLoaderRefsArrayOffset: ldrSize,
+		DependentKindArrayOffset:       0, // all deps normal
+		DepCount:                       3,
+		IndexOfTwin:                    NoUnzipperedTwin,
+	})
+	binary.Write(buf, binary.LittleEndian, []LoaderRef{NewLoaderRef(1, false), NewLoaderRef(2, false), NewLoaderRef(1, true)})
+
+	f := &File{ByteOrder: binary.LittleEndian}
+	pbl, err := f.parsePrebuiltLoader(io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len())), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pbl.Dependents) != 3 || len(pbl.DependentRefs) != 3 {
+		t.Fatalf("got %d dependents (%d refs), want 3", len(pbl.Dependents), len(pbl.DependentRefs))
+	}
+	for idx, dep := range pbl.Dependents {
+		if dep.Kind != KindNormal {
+			t.Errorf("dependent %d kind = %s, want %s", idx, dep.Kind, KindNormal)
+		}
+	}
+
+	// dependents without a LoaderRef array are inconsistent
+	buf.Reset()
+	binary.Write(buf, binary.LittleEndian, prebuiltLoaderHeader{
+		Loader:      Loader{Magic: LoaderMagic, Info: 1 /* isPrebuilt */},
+		DepCount:    1,
+		IndexOfTwin: NoUnzipperedTwin,
+	})
+	if _, err := f.parsePrebuiltLoader(io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len())), nil); err == nil {
+		t.Error("parsePrebuiltLoader() with DepCount but no LoaderRef array returned no error")
+	}
+}
+
+func TestPrebuiltLoaderCoalescedRegions(t *testing.T) {
+	const (
+		rw       = uint64(3) << 59
+		rx       = uint64(5) << 59
+		zeroFill = uint64(1) << 62
+	)
+	pl := PrebuiltLoader{
+		Regions: []Region{
+			{Info: 0x0000 | rx, FileOffset: 0x0000, FileSize: 0x4000},
+			{Info: 0x4000 | rx, FileOffset: 0x4000, FileSize: 0x4000}, // merged with the previous region
+			{Info: 0x8000 | rw, FileOffset: 0x8000, FileSize: 0x1000},
+			{Info: 0x9000 | rw, FileOffset: 0xc000, FileSize: 0x1000}, // VM contiguous but NOT file contiguous
+			{Info: 0xa000 | rw | zeroFill},
+			{Info: 0xb000 | rw | zeroFill}, // merged with the previous zero-fill region
+		},
+	}
+	orig := slices.Clone(pl.Regions)
+	got := pl.CoalescedRegions()
+	want := []Region{
+		{Info: 0x0000 | rx, FileOffset: 0x0000, FileSize: 0x8000},
+		{Info: 0x8000 | rw, FileOffset: 0x8000, FileSize: 0x1000},
+		{Info: 0x9000 | rw, FileOffset: 0xc000, FileSize: 0x1000},
+		{Info: 0xa000 | rw | zeroFill},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("CoalescedRegions() = %v, want %v", got, want)
+	}
+	if !slices.Equal(pl.Regions, orig) {
+		t.Error("CoalescedRegions() modified Regions")
+	}
+}
+
+func TestPrebuiltLoaderSetEssentialLoaders(t *testing.T) {
+	const neverUnload = 1<<0 | 1<<5 // isPrebuilt | neverUnload
+	ldr := func(idx uint16, info uint16, deps []LoaderRef, kinds []DependentKind) PrebuiltLoader {
+		pl := PrebuiltLoader{
+			prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Magic: LoaderMagic, Info: info, Ref: NewLoaderRef(idx, true)}},
+			DependentRefs:        deps,
+		}
+		for _, kind := range kinds {
+			pl.Dependents = append(pl.Dependents, dependent{Kind: kind})
+		}
+		return pl
+	}
+	pset := PrebuiltLoaderSet{
+		Loaders: []PrebuiltLoader{
+			ldr(0, neverUnload, []LoaderRef{NewLoaderRef(1, true), NewLoaderRef(2, true), NewLoaderRef(5, false)}, []DependentKind{KindNormal, KindWeakLink, KindNormal}),
+			ldr(1, neverUnload, []LoaderRef{NewLoaderRef(3, true)}, []DependentKind{KindReexport}),
+			ldr(2, neverUnload, nil, nil), // only weak-linked
+			ldr(3, 1, nil, nil),           // in the root set but may be unloaded
+		},
+	}
+	var got []uint16
+	for _, pl := range pset.EssentialLoaders() {
+		got = append(got, pl.Ref.Index())
+	}
+	if want := []uint16{0, 1}; !slices.Equal(got, want) {
+		t.Errorf("EssentialLoaders() = %v, want %v", got, want)
+	}
+}
+
+func TestPrebuiltLoaderSetValidateSelfRef(t *testing.T) {
+	f := &File{ByteOrder: binary.LittleEndian}
+	pset := PrebuiltLoaderSet{
+		Loaders: []PrebuiltLoader{
+			{prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Ref: NewLoaderRef(0, true)}, IndexOfTwin: NoUnzipperedTwin}, DependentRefs: []LoaderRef{NewLoaderRef(1, true)}},
+			{prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Ref: NewLoaderRef(1, true)}, IndexOfTwin: NoUnzipperedTwin}},
+		},
+	}
+	if errs := pset.Validate(f); len(errs) != 0 {
+		t.Fatalf("Validate() = %v, want no errors", errs)
+	}
+	pset.Loaders[1].Ref = NewLoaderRef(2, true)
+	if errs := pset.Validate(f); len(errs) != 1 {
+		t.Errorf("Validate() = %v, want a single self ref error", errs)
+	}
+}
+
+func TestPrebuiltLoaderSetLoadersWithROData(t *testing.T) {
+	const (
+		hasROData = 1 << 4
+		roData    = uint64(1) << 63
+	)
+	f := &File{ByteOrder: binary.LittleEndian}
+	ldr := func(idx uint16, info uint16, regions ...Region) PrebuiltLoader {
+		return PrebuiltLoader{
+			prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Info: info, Ref: NewLoaderRef(idx, true)}, IndexOfTwin: NoUnzipperedTwin},
+			Path:                 fmt.Sprintf("/usr/lib/lib%d.dylib", idx),
+			Regions:              regions,
+		}
+	}
+	pset := PrebuiltLoaderSet{
+		Loaders: []PrebuiltLoader{
+			ldr(0, 0, Region{Info: 0}),
+			ldr(1, hasROData, Region{Info: 0}, Region{Info: 0x4000 | roData}),
+		},
+	}
+	pset.Loaders[0].DependentRefs = []LoaderRef{NewLoaderRef(1, true)} // keep loader[1] reachable from the main executable
+	if got := pset.LoadersWithROData(); len(got) != 1 || got[0] != &pset.Loaders[1] {
+		t.Fatalf("LoadersWithROData() = %v, want loader[1]", got)
+	}
+	if got := pset.Loaders[1].ReadOnlyDataRegions(); !slices.Equal(got, []int{1}) {
+		t.Errorf("ReadOnlyDataRegions() = %v, want [1]", got)
+	}
+	if errs := pset.Validate(f); len(errs) != 0 {
+		t.Fatalf("Validate() = %v, want no errors", errs)
+	}
+
+	pset.Loaders[0].Regions[0].Info |= roData  // ro region without the flag
+	pset.Loaders[1].Regions[1].Info &^= roData // flag without a ro region
+	if errs := pset.Validate(f); len(errs) != 2 {
+		t.Errorf("Validate() = %v, want two ro-data mismatches", errs)
+	}
+}
+
+func TestPrebuiltLoaderSetEqual(t *testing.T) {
+	newSet := func() *PrebuiltLoaderSet {
+		return &PrebuiltLoaderSet{
+			PrebuiltLoaderSetHeader: PrebuiltLoaderSetHeader{Magic: PrebuiltLoaderSetMagic, LoadersArrayCount: 1},
+			Loaders: []PrebuiltLoader{{
+				Path:           "/usr/bin/foo",
+				FileValidation: &fileValidation{Inode: 1},
+			}},
+			MustBeMissingPaths: []string{"/usr/lib/libfoo.dylib"},
+		}
+	}
+	a, b := newSet(), newSet()
+	if !a.Equal(b) {
+		t.Fatal("Equal() = false for identical sets")
+	}
+	a.Loaders[0].addr, a.Loaders[0].size = 0x180004000, 0x100 // parsed from the cache
+	b.Loaders[0].addr, b.Loaders[0].size = 0, 0x100           // parsed from a closure file
+	if !a.Equal(b) {
+		t.Error("Equal() = false for identical sets parsed from different locations")
+	}
+	if a.Loaders[0].addr != 0x180004000 {
+		t.Error("Equal() modified the loaders")
+	}
+	b.Loaders[0].FileValidation.Inode = 2
+	if a.Equal(b) {
+		t.Error("Equal() = true for sets with different FileValidation")
+	}
+	if a.Equal(nil) {
+		t.Error("Equal(nil) = true")
+	}
+}
+
+func TestPrebuiltLoaderSetPlatform(t *testing.T) {
+	const (
+		supportsCatalyst   = 0x0004
+		isCatalystOverride = 0x0008
+		iOS                = types.Platform(2) // PLATFORM_IOS
+	)
+	uuid := types.UUID{1}
+	cache := func(platform types.Platform) *File {
+		return &File{UUID: uuid, Headers: map[types.UUID]CacheHeader{uuid: {Platform: platform}}}
+	}
+	ldr := func(info uint16, deps ...string) PrebuiltLoader {
+		pl := PrebuiltLoader{prebuiltLoaderHeader: prebuiltLoaderHeader{Info: info}}
+		for _, dep := range deps {
+			pl.Dependents = append(pl.Dependents, dependent{Name: dep})
+		}
+		return pl
+	}
+	tests := []struct {
+		name     string
+		platform types.Platform
+		loaders  []PrebuiltLoader
+		want     types.Platform
+	}{
+		{"ios", iOS, []PrebuiltLoader{ldr(isCatalystOverride)}, iOS},
+		{"macos", platformMacOS, []PrebuiltLoader{ldr(0, "/usr/lib/libSystem.B.dylib")}, platformMacOS},
+		{"zippered", platformMacOS, []PrebuiltLoader{ldr(supportsCatalyst, "/usr/lib/libSystem.B.dylib")}, platformMacOS},
+		{"catalyst override", platformMacOS, []PrebuiltLoader{ldr(isCatalystOverride)}, platformMacCatalyst},
+		{"ios support", platformMacOS, []PrebuiltLoader{ldr(supportsCatalyst, "/System/iOSSupport/System/Library/Frameworks/UIKit.framework/UIKit")}, platformMacCatalyst},
+	}
+	for _, tt := range tests {
+		pset := PrebuiltLoaderSet{Loaders: tt.loaders}
+		if got := pset.Platform(cache(tt.platform)); got != tt.want.String() {
+			t.Errorf("%s: Platform() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseLoaderSetAcrossSubCaches(t *testing.T) {
+	const (
+		setAddr  = 0x10000
+		boundary = 0x100 // the set's first 0x100 bytes are in one subcache, the rest are in the next
+	)
+	hdrSize := uint32(binary.Size(PrebuiltLoaderSetHeader{}))
+	ldrSize := uint32(binary.Size(prebuiltLoaderHeader{}))
+	ldr0Off := hdrSize + 8
+	ldr1Off := uint32(boundary + 0x80)
+	if ldr0Off+ldrSize > boundary {
+		t.Fatalf("loader[0] (%#x-%#x) must fit before the subcache boundary %#x", ldr0Off, ldr0Off+ldrSize, boundary)
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, PrebuiltLoaderSetHeader{
+		Magic:              PrebuiltLoaderSetMagic,
+		LoadersArrayCount:  2,
+		LoadersArrayOffset: hdrSize,
+	})
+	binary.Write(buf, binary.LittleEndian, []uint32{ldr0Off, ldr1Off})
+	binary.Write(buf, binary.LittleEndian, prebuiltLoaderHeader{
+		Loader:      Loader{Magic: LoaderMagic, Info: 1 /* isPrebuilt */, Ref: NewLoaderRef(0, true)},
+		IndexOfTwin: NoUnzipperedTwin,
+	})
+	buf.Write(make([]byte, int(ldr1Off)-buf.Len()))
+	binary.Write(buf, binary.LittleEndian, prebuiltLoaderHeader{
+		Loader:      Loader{Magic: LoaderMagic, Info: 1 /* isPrebuilt */, Ref: NewLoaderRef(1, true)},
+		PathOffset:  uint16(ldrSize),
+		IndexOfTwin: NoUnzipperedTwin,
+	})
+	buf.WriteString("/usr/lib/libfoo.dylib\x00")
+
+	sc0, sc1 := types.UUID{1}, types.UUID{2}
+	f := &File{
+		ByteOrder: binary.LittleEndian,
+		Mappings: map[types.UUID]cacheMappings{
+			sc0: {{CacheMappingInfo: CacheMappingInfo{Address: setAddr, Size: boundary, FileOffset: 0}}},
+			sc1: {{CacheMappingInfo: CacheMappingInfo{Address: setAddr + boundary, Size: 0x1000, FileOffset: 0}}},
+		},
+		r: map[types.UUID]io.ReaderAt{
+			sc0: bytes.NewReader(buf.Bytes()[:boundary]),
+			sc1: bytes.NewReader(buf.Bytes()[boundary:]),
+		},
+	}
+	pset, err := f.parseLoaderSetAtAddr(setAddr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pset.Loaders) != 2 {
+		t.Fatalf("got %d loaders, want 2", len(pset.Loaders))
+	}
+	if pset.Loaders[1].Path != "/usr/lib/libfoo.dylib" {
+		t.Errorf("loader[1] path = %q, want /usr/lib/libfoo.dylib (from the second subcache)", pset.Loaders[1].Path)
+	}
+}
+
+func TestLoaderFlagAccessors(t *testing.T) {
+	accessors := []struct {
+		name string
+		bit  int
+		get  func(Loader) bool
+	}{
+		{"IsPrebuilt", 0, Loader.IsPrebuilt},
+		{"DylibInDyldCache", 1, Loader.DylibInDyldCache},
+		{"HasObjC", 2, Loader.HasObjC},
+		{"MayHavePlusLoad", 3, Loader.MayHavePlusLoad},
+		{"HasReadOnlyData", 4, Loader.HasReadOnlyData},
+		{"NeverUnload", 5, Loader.NeverUnload},
+		{"LeaveMapped", 6, Loader.LeaveMapped},
+		{"HasReadOnlyObjC", 7, Loader.HasReadOnlyObjC},
+		{"Pre2022Binary", 8, Loader.Pre2022Binary},
+		{"IsPremapped", 9, Loader.IsPremapped},
+	}
+	for _, set := range accessors {
+		l := Loader{Info: 1 << set.bit}
+		for _, acc := range accessors {
+			if got := acc.get(l); got != (acc.bit == set.bit) {
+				t.Errorf("Loader{Info: 1<<%d}.%s() = %t", set.bit, acc.name, got)
+			}
+		}
+	}
+}
+
+func TestPrebuiltLoaderInfoAccessors(t *testing.T) {
+	tests := []struct {
+		info               uint16
+		hasInitializers    bool
+		isOverridable      bool
+		supportsCatalyst   bool
+		isCatalystOverride bool
+		regionsCount       uint16
+	}{
+		{0x0000, false, false, false, false, 0},
+		{0x0001, true, false, false, false, 0},
+		{0x0002, false, true, false, false, 0},
+		{0x0004, false, false, true, false, 0},
+		{0x0008, false, false, false, true, 0},
+		{0x0010, false, false, false, false, 1},
+		{0xfff0, false, false, false, false, 0xfff},
+		{0x0abf, true, true, true, true, 0x0ab},
+	}
+	for _, tt := range tests {
+		pl := PrebuiltLoader{prebuiltLoaderHeader: prebuiltLoaderHeader{Info: tt.info}}
+		if pl.HasInitializers() != tt.hasInitializers ||
+			pl.IsOverridable() != tt.isOverridable ||
+			pl.SupportsCatalyst() != tt.supportsCatalyst ||
+			pl.IsCatalystOverride() != tt.isCatalystOverride ||
+			pl.RegionsCount() != tt.regionsCount {
+			t.Errorf("Info %#04x = (init: %t, overridable: %t, catalyst: %t, catalyst-override: %t, regions: %d), want %+v",
+				tt.info, pl.HasInitializers(), pl.IsOverridable(), pl.SupportsCatalyst(), pl.IsCatalystOverride(), pl.RegionsCount(), tt)
+		}
+		if pl.Loader.Info != 0 {
+			t.Errorf("PrebuiltLoader.Info %#04x leaked into Loader.Info", tt.info)
+		}
+	}
+}
+
+func TestRegionAccessors(t *testing.T) {
+	tests := []struct {
+		info     uint64
+		vmOffset uint64
+		perms    types.VmProtection
+		zeroFill bool
+		roData   bool
+	}{
+		{0, 0, 0, false, false},
+		{0x07ffffffffffffff, 0x07ffffffffffffff, 0, false, false},
+		{1 << 59, 0, 1, false, false},
+		{7 << 59, 0, 7, false, false},
+		{1 << 62, 0, 0, true, false},
+		{1 << 63, 0, 0, false, true},
+		{0xd800000000004000, 0x4000, 3, true, true},
+	}
+	for _, tt := range tests {
+		r := Region{Info: tt.info}
+		if r.VMOffset() != tt.vmOffset || r.Perms() != tt.perms || r.IsZeroFill() != tt.zeroFill || r.ReadOnlyData() != tt.roData {
+			t.Errorf("Region{Info: %#x} = (vm_off: %#x, perms: %d, zerofill: %t, ro_data: %t), want %+v",
+				tt.info, r.VMOffset(), r.Perms(), r.IsZeroFill(), r.ReadOnlyData(), tt)
+		}
+	}
+}
+
+func TestLoaderRefAccessors(t *testing.T) {
+	tests := []struct {
+		ref         LoaderRef
+		index       uint16
+		isApp       bool
+		missingWeak bool
+	}{
+		{0x0000, 0, false, false},
+		{0x0001, 1, false, false},
+		{0x8000, 0, true, false},
+		{0x8123, 0x123, true, false},
+		{0x7fff, 0x7fff, false, true},
+		{0xffff, 0x7fff, true, false},
+	}
+	for _, tt := range tests {
+		if tt.ref.Index() != tt.index || tt.ref.IsApp() != tt.isApp || tt.ref.IsMissingWeakImage() != tt.missingWeak {
+			t.Errorf("LoaderRef(%#04x) = (index: %#x, app: %t, missing-weak: %t), want %+v",
+				uint16(tt.ref), tt.ref.Index(), tt.ref.IsApp(), tt.ref.IsMissingWeakImage(), tt)
+		}
+	}
+}
+
+func TestBindTargetRefAccessors(t *testing.T) {
+	tests := []struct {
+		bt       BindTargetRef
+		ref      LoaderRef
+		absolute bool
+		offset   uint64
+	}{
+		{0x0000000000000000, 0, false, 0},
+		{0x0000000123008005, 0x8005, false, 0x123},              // low39 = 0x123 (app loader 5)
+		{0x0000001000000000 | 0x8001, 0x8001, false, 0x1000},    // low39 = 0x1000
+		{0x7fffffffffff0002, 0x0002, false, 0xffffffffffffffff}, // low39 = -1 sign extended, high8 = 0xff
+		{0x0000000040ab0003, 0x0003, false, 0xab00000000000040}, // high8 = 0xab
+		{0x8000000000001234, 0x1234, true, 0x1234},
+		{0xc000000000000000, 0, true, 0xc000000000000000}, // absolute value sign extended from bit 62
+	}
+	for _, tt := range tests {
+		if tt.bt.IsAbsolute() != tt.absolute || tt.bt.Offset() != tt.offset {
+			t.Errorf("BindTargetRef(%#016x) = (absolute: %t, offset: %#x), want (absolute: %t, offset: %#x)",
+				uint64(tt.bt), tt.bt.IsAbsolute(), tt.bt.Offset(), tt.absolute, tt.offset)
+		}
+		if !tt.absolute && tt.bt.LoaderRef() != tt.ref {
+			t.Errorf("BindTargetRef(%#016x).LoaderRef() = %#04x, want %#04x", uint64(tt.bt), uint16(tt.bt.LoaderRef()), uint16(tt.ref))
+		}
+	}
+}
+
+func TestParsePrebuiltLoaderSingletonPatch(t *testing.T) {
+	ldrSize := uint32(binary.Size(prebuiltLoaderHeader{}))
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, prebuiltLoaderHeader{
+		Loader:           Loader{Magic: LoaderMagic, Info: 1 /* isPrebuilt */, Ref: NewLoaderRef(0, true)},
+		IndexOfTwin:      NoUnzipperedTwin,
+		PatchTableOffset: ldrSize,
+	})
+	binary.Write(buf, binary.LittleEndian, []DylibPatch{
+		{OverrideOffsetOfImpl: 0x4000, Kind: DylibPatchKindSingleton},
+		{OverrideOffsetOfImpl: 0, Kind: DylibPatchKindMissingWeakImport},
+		{Kind: DylibPatchKindEndOfTable},
+	})
+
+	f := &File{ByteOrder: binary.LittleEndian}
+	pbl, err := f.parsePrebuiltLoader(io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len())), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pbl.DylibPatches) != 3 || pbl.DylibPatches[0].Kind != DylibPatchKindSingleton {
+		t.Fatalf("DylibPatches = %v, want a singleton, a missing weak import and the end marker", pbl.DylibPatches)
+	}
+	out := pbl.String(f)
+	for _, want := range []string{"Dylib Patches:", "0x00004000: singleton", "missing-weak-import"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("String() does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrebuiltLoaderSingletonPatchName(t *testing.T) {
+	// the root of libfoo patches the 2nd patchable export of the cache's libfoo (a singleton object)
+	f := &File{
+		ByteOrder: binary.LittleEndian,
+		Images: []*CacheImage{
+			{Name: "/usr/lib/libSystem.B.dylib"},
+			{Name: "/usr/lib/libfoo.dylib", PatchableExports: []Patch{
+				PatchableExport{Name: "_foo_func"},
+				PatchableExport{Name: "_kFooSingleton"},
+			}},
+		},
+	}
+	root := PrebuiltLoader{
+		Path: "/usr/lib/libfoo.dylib",
+		DylibPatches: []DylibPatch{
+			{Kind: DylibPatchKindMissingWeakImport},
+			{OverrideOffsetOfImpl: 0x4000, Kind: DylibPatchKindSingleton},
+			{Kind: DylibPatchKindEndOfTable},
+		},
+	}
+	root.Ref = NewLoaderRef(0, true)
+
+	if name, err := f.DylibPatchExportName(&root, 1); err != nil || name != "_kFooSingleton" {
+		t.Errorf("DylibPatchExportName(1) = %q, %v, want _kFooSingleton", name, err)
+	}
+	if _, err := f.DylibPatchExportName(&root, 2); err == nil {
+		t.Error("DylibPatchExportName() past the overridden dylib's patchable exports returned no error")
+	}
+	if out := root.StringVerbose(f); !strings.Contains(out, "0x00004000: singleton (every cache use is patched to the root's single instance) _kFooSingleton\n") {
+		t.Errorf("StringVerbose() does not name the singleton:\n%s", out)
+	}
+	other := PrebuiltLoader{Path: "/Applications/Foo.app/Foo"}
+	if _, err := f.DylibPatchExportName(&other, 0); err == nil {
+		t.Error("DylibPatchExportName() of a loader that overrides no cache dylib returned no error")
+	}
+}
+
+func TestRegionMarshalJSON(t *testing.T) {
+	r := Region{Info: 0x4000 | uint64(3)<<59 | 1<<63, FileOffset: 0x8000, FileSize: 0x1000}
+	got, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"vmOffset":"0x4000","fileOffset":"0x8000","fileSize":"0x1000","perms":"rw-","isZeroFill":false,"readOnlyData":true}`
+	if string(got) != want {
+		t.Errorf("json.Marshal(Region) = %s, want %s", got, want)
+	}
+}
+
+func TestLoaderRefMarshalJSON(t *testing.T) {
+	tests := []struct {
+		ref  LoaderRef
+		want string
+	}{
+		{NewLoaderRef(5, false), `{"index":5,"isApp":false,"missingWeakImage":false}`},
+		{NewLoaderRef(3, true), `{"index":3,"isApp":true,"missingWeakImage":false}`},
+		{NewMissingWeakImageRef(), `{"index":32767,"isApp":false,"missingWeakImage":true}`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("json.Marshal(%s) = %s, want %s", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestBindTargetRefMarshalJSON(t *testing.T) {
+	tests := []struct {
+		bt   BindTargetRef
+		want string
+	}{
+		{NewBindTargetRef(NewLoaderRef(2, false), 0x1234), `{"isAbsolute":false,"loaderRef":{"index":2,"isApp":false,"missingWeakImage":false},"offset":"0x1234"}`},
+		{NewBindTargetRef(NewLoaderRef(1, true), -0x10), `{"isAbsolute":false,"loaderRef":{"index":1,"isApp":true,"missingWeakImage":false},"offset":"0xfffffffffffffff0"}`},
+		{NewAbsoluteBindTargetRef(0x42), `{"isAbsolute":true,"offset":"0x42"}`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.bt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("json.Marshal(%#x) = %s, want %s", uint64(tt.bt), got, tt.want)
+		}
+	}
+}
+
+// signedMachO builds a minimal arm64 MachO whose code signature has a single (empty) code directory with the given identifier
+func signedMachO(t *testing.T, ident string) ([]byte, [20]byte) {
+	t.Helper()
+
+	be := binary.BigEndian
+	le := binary.LittleEndian
+
+	cd := make([]byte, 44)
+	be.PutUint32(cd[0:], 0xfade0c02) // CSMAGIC_CODEDIRECTORY
+	be.PutUint32(cd[8:], 0x20001)    // version (earliest)
+	be.PutUint32(cd[20:], 0)         // nSpecialSlots
+	be.PutUint32(cd[24:], 0)         // nCodeSlots
+	cd[36] = 32                      // hashSize
+	cd[37] = 2                       // hashType (SHA256)
+	cd[39] = 12                      // pageSize
+	cd = append(cd, append([]byte(ident), 0)...)
+	be.PutUint32(cd[12:], uint32(len(cd))) // hashOffset
+	be.PutUint32(cd[16:], 44)              // identOffset
+	be.PutUint32(cd[4:], uint32(len(cd)))  // length
+
+	sb := make([]byte, 20)
+	be.PutUint32(sb[0:], 0xfade0cc0) // CSMAGIC_EMBEDDED_SIGNATURE
+	be.PutUint32(sb[4:], uint32(len(sb)+len(cd)))
+	be.PutUint32(sb[8:], 1)   // count
+	be.PutUint32(sb[12:], 0)  // CSSLOT_CODEDIRECTORY
+	be.PutUint32(sb[16:], 20) // offset
+	sb = append(sb, cd...)
+
+	hdr := make([]byte, 48)
+	le.PutUint32(hdr[0:], 0xfeedfacf)       // MH_MAGIC_64
+	le.PutUint32(hdr[4:], 0x0100000c)       // CPU_TYPE_ARM64
+	le.PutUint32(hdr[12:], 2)               // MH_EXECUTE
+	le.PutUint32(hdr[16:], 1)               // ncmds
+	le.PutUint32(hdr[20:], 16)              // sizeofcmds
+	le.PutUint32(hdr[32:], 0x1d)            // LC_CODE_SIGNATURE
+	le.PutUint32(hdr[36:], 16)              // cmdsize
+	le.PutUint32(hdr[40:], 48)              // dataoff
+	le.PutUint32(hdr[44:], uint32(len(sb))) // datasize
+
+	var cdhash [20]byte
+	sum := sha256.Sum256(cd)
+	copy(cdhash[:], sum[:20])
+
+	return append(hdr, sb...), cdhash
+}
+
+func TestPrebuiltLoaderValidateAgainstFatSlice(t *testing.T) {
+	x86, x86Hash := signedMachO(t, "com.example.x86_64")
+	arm, armHash := signedMachO(t, "com.example.arm64")
+	if x86Hash == armHash {
+		t.Fatal("slices must have different CDHashes")
+	}
+
+	const x86Off, armOff = 0x100, 0x200
+	fat := make([]byte, armOff+len(arm))
+	binary.BigEndian.PutUint32(fat[0:], 0xcafebabe) // FAT_MAGIC
+	binary.BigEndian.PutUint32(fat[4:], 2)
+	copy(fat[x86Off:], x86)
+	copy(fat[armOff:], arm)
+
+	pl := PrebuiltLoader{
+		Path: "/usr/bin/fat",
+		FileValidation: &fileValidation{
+			SliceOffset: armOff,
+			CDHash:      armHash,
+			CheckCDHash: true,
+		},
+	}
+	r := bytes.NewReader(fat)
+	if err := pl.ValidateAgainst(r, r.Size()); err != nil {
+		t.Fatalf("ValidateAgainst(arm64 slice) = %v", err)
+	}
+
+	// the same CDHash does NOT match when the validation points at the other slice
+	pl.FileValidation.SliceOffset = x86Off
+	var mismatch *CDHashMismatchError
+	if err := pl.ValidateAgainst(r, r.Size()); !errors.As(err, &mismatch) {
+		t.Fatalf("ValidateAgainst(x86_64 slice) = %v, want CDHashMismatchError", err)
+	} else if mismatch.SliceOffset != x86Off {
+		t.Errorf("mismatch SliceOffset = %#x, want %#x", mismatch.SliceOffset, x86Off)
+	}
+
+	pl.FileValidation.SliceOffset = uint64(len(fat))
+	var rangeErr *OffsetRangeError
+	if err := pl.ValidateAgainst(r, r.Size()); !errors.As(err, &rangeErr) {
+		t.Fatalf("ValidateAgainst(out of range slice) = %v, want OffsetRangeError", err)
+	}
+}
+
+func TestPrebuiltLoaderSetAllSelectors(t *testing.T) {
+	pls := PrebuiltLoaderSet{
+		Loaders: []PrebuiltLoader{
+			{ObjcSelectorFixupNames: []string{"init", "", "dealloc"}},
+			{ObjcSelectorFixupNames: []string{"init", "alloc"}},
+		},
+		SelectorTable: &ObjCSelectorOpt{
+			Names: []string{"", "viewDidLoad", "alloc"},
+		},
+	}
+	want := []string{"alloc", "dealloc", "init", "viewDidLoad"}
+	if got := pls.AllSelectors(); !slices.Equal(got, want) {
+		t.Errorf("AllSelectors() = %v, want %v", got, want)
+	}
+	if got := (&PrebuiltLoaderSet{}).AllSelectors(); len(got) != 0 {
+		t.Errorf("AllSelectors() of empty set = %v, want none", got)
+	}
+}
+
+func TestParseOverrideBindTargetNames(t *testing.T) {
+	hdrSize := uint32(binary.Size(PrebuiltLoaderSetHeader{}))
+	ldrSize := uint32(binary.Size(prebuiltLoaderHeader{}))
+
+	const (
+		appPath = "/Applications/Foo.app/Foo"
+		fwPath  = "/Applications/Foo.app/Frameworks/Bar.framework/Bar"
+	)
+	overrides := []BindTargetRef{
+		NewBindTargetRef(NewLoaderRef(1, true), 0x1000),  // into the app's embedded framework
+		NewBindTargetRef(NewLoaderRef(0, false), 0x2000), // into a cache dylib
+		NewBindTargetRef(NewLoaderRef(7, true), 0x3000),  // out of range app loader
+	}
+
+	buf := new(bytes.Buffer)
+	loadersOff := hdrSize
+	ldr0Off := loadersOff + 8
+	ldr1Off := ldr0Off + ldrSize + 0x20 + uint32(8*len(overrides))
+	binary.Write(buf, binary.LittleEndian, PrebuiltLoaderSetHeader{
+		Magic:              PrebuiltLoaderSetMagic,
+		LoadersArrayCount:  2,
+		LoadersArrayOffset: loadersOff,
+	})
+	binary.Write(buf, binary.LittleEndian, []uint32{ldr0Off, ldr1Off})
+	binary.Write(buf, binary.LittleEndian, prebuiltLoaderHeader{
+		Loader:                       Loader{Magic: LoaderMagic, Info: 1 /* isPrebuilt */, Ref: NewLoaderRef(0, true)},
+		PathOffset:                   uint16(ldrSize),
+		IndexOfTwin:                  NoUnzipperedTwin,
+		OverrideBindTargetRefsOffset: ldrSize + 0x20,
+		OverrideBindTargetRefsCount:  uint32(len(overrides)),
+	})
+	path := make([]byte, 0x20)
+	copy(path, appPath)
+	buf.Write(path)
+	binary.Write(buf, binary.LittleEndian, overrides)
+	binary.Write(buf, binary.LittleEndian, prebuiltLoaderHeader{
+		Loader:      Loader{Magic: LoaderMagic, Info: 1 /* isPrebuilt */, Ref: NewLoaderRef(1, true)},
+		PathOffset:  uint16(ldrSize),
+		IndexOfTwin: NoUnzipperedTwin,
+	})
+	path = make([]byte, 0x40)
+	copy(path, fwPath)
+	buf.Write(path)
+
+	pset, err := ParseLoaderSetAt(bytes.NewReader(buf.Bytes()), 0, []*CacheImage{{Name: "/usr/lib/libBar.dylib"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{fwPath, "/usr/lib/libBar.dylib", ""}
+	if got := pset.Loaders[0].OverrideBindTargetNames; !slices.Equal(got, want) {
+		t.Errorf("OverrideBindTargetNames = %q, want %q", got, want)
+	}
+	if got := pset.Loaders[1].OverrideBindTargetNames; got != nil {
+		t.Errorf("loader without overrides has OverrideBindTargetNames %q", got)
+	}
+}
+
+func TestPrebuiltLoaderSetVerifyAgainstRoot(t *testing.T) {
+	good, goodHash := signedMachO(t, "com.example.good")
+	bad, _ := signedMachO(t, "com.example.bad")
+	_, otherHash := signedMachO(t, "com.example.other")
+
+	root := t.TempDir()
+	for path, dat := range map[string][]byte{
+		"usr/lib/libgood.dylib":  good,
+		"usr/lib/libbad.dylib":   bad,
+		"usr/lib/libnone.dylib":  good,
+		"usr/lib/libfresh.dylib": good,
+		"usr/lib/libstale.dylib": good,
+		"usr/lib/libextra.dylib": good,
+		"usr/lib/README":         []byte("not a Mach-O"),
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, path), dat, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fi, err := os.Stat(filepath.Join(root, "usr/lib/libfresh.dylib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inode, _ := fileInode(fi)
+	mtime := uint64(fi.ModTime().Unix())
+
+	pls := PrebuiltLoaderSet{
+		Loaders: []PrebuiltLoader{
+			{Path: "/usr/lib/libgood.dylib", FileValidation: &fileValidation{CDHash: goodHash, CheckCDHash: true}},
+			{Path: "/usr/lib/libbad.dylib", FileValidation: &fileValidation{CDHash: otherHash, CheckCDHash: true}},
+			{Path: "/usr/lib/libgone.dylib", FileValidation: &fileValidation{CDHash: goodHash, CheckCDHash: true}},
+			{Path: "/usr/lib/libnone.dylib"},
+			{Path: "/usr/lib/libnothere.dylib"},
+			{Path: "/usr/lib/libfresh.dylib", FileValidation: &fileValidation{Inode: inode, Mtime: mtime, CheckInodeMtime: true}},
+			{Path: "/usr/lib/libstale.dylib", FileValidation: &fileValidation{Inode: inode, Mtime: mtime - 60, CheckInodeMtime: true}},
+		},
+	}
+	report, err := pls.VerifyAgainstRoot(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(report.Verified, []string{"/usr/lib/libgood.dylib", "/usr/lib/libfresh.dylib"}) {
+		t.Errorf("Verified = %q", report.Verified)
+	}
+	if !slices.Equal(report.Missing, []string{"/usr/lib/libgone.dylib", "/usr/lib/libnothere.dylib"}) {
+		t.Errorf("Missing = %q", report.Missing)
+	}
+	if !slices.Equal(report.Unverified, []string{"/usr/lib/libnone.dylib"}) {
+		t.Errorf("Unverified = %q", report.Unverified)
+	}
+	if !slices.Equal(report.InodeMtimeChanged, []string{"/usr/lib/libstale.dylib"}) {
+		t.Errorf("InodeMtimeChanged = %q", report.InodeMtimeChanged)
+	}
+	if !slices.Equal(report.Extra, []string{"/usr/lib/libextra.dylib"}) {
+		t.Errorf("Extra = %q", report.Extra)
+	}
+	var mismatch *CDHashMismatchError
+	if len(report.Mismatched) != 1 || !errors.As(report.Mismatched["/usr/lib/libbad.dylib"], &mismatch) {
+		t.Errorf("Mismatched = %v, want a CDHashMismatchError for libbad", report.Mismatched)
+	}
+	if report.OK() {
+		t.Error("OK() = true with missing and mismatched binaries")
+	}
+}
+
+func TestClosureCacheInvalidatesOnCacheUUIDChange(t *testing.T) {
+	f := &File{UUID: types.UUID{1}}
+	c := NewClosureCache(f)
+	loads := 0
+	c.load = func(execPath string) (*PrebuiltLoaderSet, error) {
+		loads++
+		return &PrebuiltLoaderSet{DyldCacheUUID: f.UUID}, nil
+	}
+
+	first, err := c.Get("/usr/bin/true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.Get("/usr/bin/true"); again != first || loads != 1 {
+		t.Fatalf("second Get should be served from the cache (loads=%d)", loads)
+	}
+
+	// re-point the File at a different cache
+	f.UUID = types.UUID{2}
+	fresh, err := c.Get("/usr/bin/true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh == first || loads != 2 {
+		t.Fatalf("Get after the cache UUID changed returned stale data (loads=%d)", loads)
+	}
+	if fresh.DyldCacheUUID != f.UUID {
+		t.Errorf("closure is from cache %s, want %s", fresh.DyldCacheUUID, f.UUID)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", c.Len())
+	}
+
+	c.Invalidate()
+	if c.Len() != 0 {
+		t.Errorf("Len() after Invalidate = %d, want 0", c.Len())
+	}
+}
+
+func TestPrebuiltLoaderSetReachableLoaders(t *testing.T) {
+	app := func(idx uint16) LoaderRef { return NewLoaderRef(idx, true) }
+	pls := PrebuiltLoaderSet{
+		Loaders: []PrebuiltLoader{
+			{prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Ref: app(0)}}, Path: "/main", DependentRefs: []LoaderRef{app(1), NewLoaderRef(3, false)}},
+			{prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Ref: app(1)}}, Path: "/fw", DependentRefs: []LoaderRef{app(2), NewMissingWeakImageRef()}},
+			{prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Ref: app(2)}}, Path: "/fw2", DependentRefs: []LoaderRef{app(1)}},
+			{prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Ref: app(3)}}, Path: "/dead", DependentRefs: []LoaderRef{app(4)}},
+			{prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Ref: app(4)}}, Path: "/dead-dep"},
+		},
+	}
+	reachable := pls.ReachableLoaders()
+	for idx, want := range []bool{true, true, true, false, false} {
+		if reachable[idx] != want {
+			t.Errorf("reachable[%d] (%s) = %t, want %t", idx, pls.Loaders[idx].Path, reachable[idx], want)
+		}
+	}
+
+	f := &File{Images: make([]*CacheImage, 4)}
+	errs := pls.Validate(f)
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "/dead") {
+		t.Errorf("Validate() = %v, want 2 unreachable loader errors", errs)
+	}
+
+	if (&PrebuiltLoaderSet{}).ReachableLoaders() != nil {
+		t.Error("ReachableLoaders() of a set without a main executable should be nil")
+	}
+}
+
+func TestPrebuiltLoaderOrigin(t *testing.T) {
+	const inCache = 1<<1 | 1 // dylibInDyldCache | isPrebuilt
+	tests := []struct {
+		ldr  Loader
+		want LoaderOrigin
+	}{
+		{Loader{Info: 1, Ref: NewLoaderRef(0, true)}, OriginMainExecutable},
+		{Loader{Info: 1, Ref: NewLoaderRef(3, true)}, OriginAppEmbedded},
+		{Loader{Info: 1, Ref: NewLoaderRef(3, false)}, OriginCacheDylib},
+		{Loader{Info: inCache, Ref: NewLoaderRef(0, false)}, OriginCacheDylib},
+	}
+	for _, tt := range tests {
+		pl := PrebuiltLoader{prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: tt.ldr}}
+		if got := pl.Origin(); got != tt.want {
+			t.Errorf("Origin() of %s = %s, want %s", tt.ldr, got, tt.want)
+		}
+	}
+
+	// an embedded framework with an upward link back to the executable makes every app loader a dependent
+	pset, err := ParseLoaderSetAt(bytes.NewReader(buildTestLoaderSet(t,
+		testLoader{Path: "/Applications/Foo.app/Foo", Dependents: []LoaderRef{NewLoaderRef(1, true)}},
+		testLoader{
+			Path:       "/Applications/Foo.app/Frameworks/Bar.framework/Bar",
+			Dependents: []LoaderRef{NewLoaderRef(0, true)},
+			Kinds:      []DependentKind{KindUpward},
+		},
+	)), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, ok := pset.MainExecutable()
+	if !ok || main.Path != "/Applications/Foo.app/Foo" || main.Origin() != OriginMainExecutable {
+		t.Errorf("MainExecutable() = %v, %t, want Foo", main, ok)
+	}
+	if got := pset.Loaders[1].Origin(); got != OriginAppEmbedded {
+		t.Errorf("Origin() of Bar = %s, want %s", got, OriginAppEmbedded)
+	}
+}
+
+func TestPrebuiltLoaderSetValidateObjC(t *testing.T) {
+	const (
+		isPrebuilt = 1 << 0
+		hasObjC    = 1 << 2
+	)
+	newSet := func(info uint16, objcOff uint32) *PrebuiltLoaderSet {
+		return &PrebuiltLoaderSet{
+			Loaders: []PrebuiltLoader{{
+				prebuiltLoaderHeader: prebuiltLoaderHeader{
+					Loader:               Loader{Info: info, Ref: NewLoaderRef(0, true)},
+					IndexOfTwin:          NoUnzipperedTwin,
+					ObjcBinaryInfoOffset: objcOff,
+				},
+				Path: "/usr/bin/foo",
+			}},
+		}
+	}
+	f := &File{}
+	tests := []struct {
+		info    uint16
+		objcOff uint32
+		bad     bool
+	}{
+		{isPrebuilt | hasObjC, 0x100, false},
+		{isPrebuilt, 0, false},
+		{isPrebuilt | hasObjC, 0, true},
+		{isPrebuilt, 0x100, true},
+		{hasObjC, 0, false}, // JIT loaders have no objc binary info
+	}
+	for _, tt := range tests {
+		errs := newSet(tt.info, tt.objcOff).Validate(f)
+		if got := len(errs) > 0; got != tt.bad {
+			t.Errorf("Validate(info=%#x, objc-offset=%#x) = %v, want error: %t", tt.info, tt.objcOff, errs, tt.bad)
+		}
+	}
+}
+
+// buildTestCache returns a minimal single file cache whose ProgramTrie maps each exec path to a closure
+// with a VersionHash of its index
+func buildTestCache(t *testing.T, execPaths ...string) []byte {
+	t.Helper()
+
+	psetSize := binary.Size(PrebuiltLoaderSetHeader{})
+	psets := make([][]byte, len(execPaths))
+	for idx := range execPaths {
+		buf := new(bytes.Buffer)
+		binary.Write(buf, binary.LittleEndian, PrebuiltLoaderSetHeader{
+			Magic:       PrebuiltLoaderSetMagic,
+			VersionHash: uint32(idx),
+		})
+		psets[idx] = buf.Bytes()[:psetSize]
+	}
+	return buildTestCacheWithSets(t, execPaths, psets)
+}
+
+// buildTestCacheWithSets synthesizes a single mapping cache whose ProgramTrie maps each exec path to its loader set
+// (e.g. a buildTestLoaderSet blob) in the pool
+func buildTestCacheWithSets(t *testing.T, execPaths []string, psets [][]byte) []byte {
+	t.Helper()
+
+	const base = 0x180000000
+	hdrSize := uint32(binary.Size(CacheHeader{}))
+	mapOff := hdrSize
+	trieOff := mapOff + uint32(binary.Size(CacheMappingInfo{}))
+
+	// root: no terminal info and a child per exec path (each child: terminal info with the pool offset, no children)
+	var children [][]byte
+	var poolSize uint64
+	for idx := range execPaths {
+		value := binary.AppendUvarint(nil, poolSize)
+		children = append(children, append(append([]byte{byte(len(value))}, value...), 0))
+		poolSize += uint64(len(psets[idx]))
+	}
+	rootSize := 2
+	for _, path := range execPaths {
+		rootSize += len(path) + 1 + 1
+	}
+	trie := []byte{0, byte(len(execPaths))}
+	childOff := rootSize
+	for idx, path := range execPaths {
+		trie = append(trie, path+"\x00"...)
+		trie = append(trie, byte(childOff))
+		childOff += len(children[idx])
+	}
+	if childOff >= 0x80 {
+		t.Fatal("test cache trie child offsets must fit in a single uleb128 byte")
+	}
+	for _, child := range children {
+		trie = append(trie, child...)
+	}
+	poolOff := (trieOff + uint32(len(trie)) + 7) &^ 7
+	if uint64(poolOff)+poolSize > 0x1000 {
+		t.Fatalf("test cache loader sets (%#x bytes) do not fit in its mapping", poolSize)
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, CacheHeader{
+		MappingOffset:          mapOff,
+		MappingCount:           1,
+		ProgramTrieAddr:        base + uint64(trieOff),
+		ProgramTrieSize:        uint32(len(trie)),
+		ProgramsPblSetPoolAddr: base + uint64(poolOff),
+		UUID:                   types.UUID{0xca, 0xfe},
+	})
+	binary.Write(buf, binary.LittleEndian, CacheMappingInfo{Address: base, Size: 0x1000})
+	buf.Write(trie)
+	buf.Write(make([]byte, int(poolOff)-buf.Len()))
+	for _, pset := range psets {
+		buf.Write(pset)
+	}
+	buf.Write(make([]byte, 0x1000-buf.Len()))
+
+	return buf.Bytes()
+}
+
+func TestParseAllClosures(t *testing.T) {
+	dat := buildTestCache(t, "/usr/bin/foo")
+	sets, err := ParseAllClosures(bytes.NewReader(dat), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 1 || sets[0].ExecPath != "/usr/bin/foo" || sets[0].VersionHash != 0 {
+		t.Fatalf("ParseAllClosures() = %+v, want the closure of /usr/bin/foo", sets)
+	}
+}
+
+func TestForEachLaunchLoaderSetFrom(t *testing.T) {
+	paths := []string{"/a", "/b", "/c", "/d"}
+	f, err := newSingleFileCache(bytes.NewReader(buildTestCache(t, paths...)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	var total int
+	if err := f.ForEachLaunchLoaderSetFrom(0, func(execPath string, pset *PrebuiltLoaderSet) {
+		seen = append(seen, execPath)
+	}, func(_, n int) {
+		total = n
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(seen, paths) || total != len(paths) {
+		t.Fatalf("walked %q (total %d), want %q", seen, total, paths)
+	}
+
+	// resume after the first 2 closures (the progress done value of an interrupted walk)
+	const checkpoint = 2
+	var resumed []string
+	var versions []uint32
+	var last int
+	if err := f.ForEachLaunchLoaderSetFrom(checkpoint, func(execPath string, pset *PrebuiltLoaderSet) {
+		resumed = append(resumed, execPath)
+		versions = append(versions, pset.VersionHash)
+	}, func(done, total int) {
+		last = done
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(resumed, paths[2:]) || !slices.Equal(versions, []uint32{2, 3}) {
+		t.Errorf("resumed walk = %q (versions %v), want %q", resumed, versions, paths[2:])
+	}
+	if last != len(paths) {
+		t.Errorf("last progress = %d, want %d", last, len(paths))
+	}
+}
+
+func TestParseAppDependentNames(t *testing.T) {
+	hdrSize := uint32(binary.Size(PrebuiltLoaderSetHeader{}))
+	ldrSize := uint32(binary.Size(prebuiltLoaderHeader{}))
+
+	const (
+		appPath     = "/Applications/Foo.app/Foo"
+		fwPath      = "/Applications/Foo.app/Frameworks/Bar.framework/Bar"
+		fwInstall   = "@rpath/Bar.framework/Bar"
+		pathsSize   = 0x40
+		depsSize    = 4 // 2 LoaderRefs
+		altPathSize = 0x20
+	)
+	ldr0Size := ldrSize + pathsSize + depsSize
+
+	buf := new(bytes.Buffer)
+	loadersOff := hdrSize
+	ldr0Off := loadersOff + 8
+	ldr1Off := ldr0Off + ldr0Size
+	binary.Write(buf, binary.LittleEndian, PrebuiltLoaderSetHeader{
+		Magic:              PrebuiltLoaderSetMagic,
+		LoadersArrayCount:  2,
+		LoadersArrayOffset: loadersOff,
+	})
+	binary.Write(buf, binary.LittleEndian, []uint32{ldr0Off, ldr1Off})
+	// the main executable depends on its embedded framework (app loader 1) and a cache dylib (image 0)
+	binary.Write(buf, binary.LittleEndian, prebuiltLoaderHeader{
+		Loader:                         Loader{Magic: LoaderMagic, Info: 1 /* isPrebuilt */, Ref: NewLoaderRef(0, true)},
+		PathOffset:                     uint16(ldrSize),
+		IndexOfTwin:                    NoUnzipperedTwin,
+		DepCount:                       2,
+		DependentLoaderRefsArrayOffset: uint16(ldrSize + pathsSize),
+	})
+	path := make([]byte, pathsSize)
+	copy(path, appPath)
+	buf.Write(path)
+	binary.Write(buf, binary.LittleEndian, []LoaderRef{NewLoaderRef(1, true), NewLoaderRef(0, false)})
+	binary.Write(buf, binary.LittleEndian, prebuiltLoaderHeader{
+		Loader:        Loader{Magic: LoaderMagic, Info: 1 /* isPrebuilt */, Ref: NewLoaderRef(1, true)},
+		PathOffset:    uint16(ldrSize),
+		AltPathOffset: uint16(ldrSize + pathsSize),
+		IndexOfTwin:   NoUnzipperedTwin,
+	})
+	path = make([]byte, pathsSize+altPathSize)
+	copy(path, fwPath)
+	copy(path[pathsSize:], fwInstall)
+	buf.Write(path)
+
+	// image 1 would be (wrongly) picked for app loader 1 if app refs were resolved against the cache images
+	images := []*CacheImage{{Name: "/usr/lib/libSystem.B.dylib"}, {Name: "/usr/lib/libWrong.dylib"}}
+	pset, err := ParseLoaderSetAt(bytes.NewReader(buf.Bytes()), 0, images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pset.Loaders[1].AltPath != fwInstall {
+		t.Fatalf("loader[1] AltPath = %q, want %q", pset.Loaders[1].AltPath, fwInstall)
+	}
+	var got []string
+	for _, dep := range pset.Loaders[0].Dependents {
+		got = append(got, dep.Name)
+	}
+	if want := []string{fwPath, "/usr/lib/libSystem.B.dylib"}; !slices.Equal(got, want) {
+		t.Errorf("dependents = %q, want %q", got, want)
+	}
+}
+
+func TestObjCBinaryInfoFlags(t *testing.T) {
+	o := ObjCBinaryInfo{
+		HasClassStableSwiftFixups:      true,
+		HasProtocolMethodListsToUnique: true,
+	}
+	flags := o.Flags()
+	if want := ObjCHasClassStableSwiftFixups | ObjCHasProtocolMethodListsToUnique; flags != want {
+		t.Errorf("Flags() = %#x, want %#x", uint8(flags), uint8(want))
+	}
+	if !flags.Has(ObjCHasClassStableSwiftFixups) || flags.Has(ObjCHasClassStableSwiftFixups|ObjCHasClassMethodListsToUnique) {
+		t.Errorf("Has() is wrong for %s", flags)
+	}
+	if got, want := flags.String(), "class-stable-swift-fixups|protocol-method-lists-to-unique"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if !strings.Contains(o.String(), "    - protocol-method-lists-to-unique\n") {
+		t.Errorf("ObjCBinaryInfo.String() is missing its flags:\n%s", o.String())
+	}
+}
+
+func TestPrebuiltLoaderSetDependentKindCounts(t *testing.T) {
+	pls := PrebuiltLoaderSet{
+		Loaders: []PrebuiltLoader{
+			{Dependents: []dependent{{Name: "a", Kind: KindNormal}, {Name: "b", Kind: KindWeakLink}, {Name: "c", Kind: KindReexport}}},
+			{Dependents: []dependent{{Name: "d", Kind: KindNormal}, {Name: "e", Kind: KindUpward}, {Name: "f", Kind: KindReexport}}},
+			{},
+		},
+	}
+	want := map[DependentKind]int{KindNormal: 2, KindWeakLink: 1, KindReexport: 2, KindUpward: 1}
+	got := pls.DependentKindCounts()
+	if len(got) != len(want) {
+		t.Fatalf("DependentKindCounts() = %v, want %v", got, want)
+	}
+	for kind, n := range want {
+		if got[kind] != n {
+			t.Errorf("DependentKindCounts()[%s] = %d, want %d", kind, got[kind], n)
+		}
+	}
+	if !strings.Contains(pls.Summary(), "dependents: (regular: 2, weak: 1, reexport: 2, upward: 1)") {
+		t.Errorf("Summary() is missing the dependent kinds: %s", pls.Summary())
+	}
+}
+
+func TestBindTargetRefStringWithImages(t *testing.T) {
+	images := []*CacheImage{{Name: "/usr/lib/libSystem.B.dylib"}}
+	loaders := []PrebuiltLoader{{Path: "/Applications/Foo.app/Foo"}, {Path: "/Applications/Foo.app/Frameworks/Bar.framework/Bar"}}
+	tests := []struct {
+		name string
+		bt   BindTargetRef
+		want string
+	}{
+		{"cache", NewBindTargetRef(NewLoaderRef(0, false), 0x10), "0x00000010: /usr/lib/libSystem.B.dylib"},
+		{"app", NewBindTargetRef(NewLoaderRef(1, true), 0x20), "0x00000020: /Applications/Foo.app/Frameworks/Bar.framework/Bar"},
+		{"app out of range", NewBindTargetRef(NewLoaderRef(5, true), 0x20), fmt.Sprintf("0x00000020: (%s)", NewLoaderRef(5, true))},
+		{"cache out of range", NewBindTargetRef(NewLoaderRef(3, false), 0x30), fmt.Sprintf("0x00000030: (%s)", NewLoaderRef(3, false))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.bt.StringWithImages(images, loaders); got != tt.want {
+				t.Errorf("StringWithImages() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// testLoader describes a prebuilt loader for buildTestLoaderSet
+type testLoader struct {
+	Path        string
+	AltPath     string
+	Ref         LoaderRef // defaults to an app ref of the loader's index
+	InCache     bool
+	Dependents  []LoaderRef
+	Kinds       []DependentKind // only emitted if set (a missing kinds array means all dependents are normal)
+	Regions     []Region
+	BindTargets []BindTargetRef
+	VmSize      uint32
+}
+
+// buildTestLoaderSet synthesizes an in-memory PrebuiltLoaderSet blob with the given loaders laid out the way
+// dyld writes them (header, path chars, dependents, kinds, regions and then bind targets)
+func buildTestLoaderSet(t *testing.T, loaders ...testLoader) []byte {
+	t.Helper()
+
+	align := func(b []byte) []byte {
+		for len(b)%8 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+
+	hdrSize := uint32(binary.Size(PrebuiltLoaderSetHeader{}))
+	ldrSize := binary.Size(prebuiltLoaderHeader{})
+
+	var blobs [][]byte
+	for idx, tl := range loaders {
+		hdr := prebuiltLoaderHeader{
+			Loader:      Loader{Magic: LoaderMagic, Info: 1 /* isPrebuilt */, Ref: tl.Ref},
+			IndexOfTwin: NoUnzipperedTwin,
+			VmSize:      tl.VmSize,
+		}
+		if tl.Ref == 0 {
+			hdr.Ref = NewLoaderRef(uint16(idx), true)
+		}
+		if tl.InCache {
+			hdr.Loader.Info |= 1 << 1
+		}
+		if len(tl.Regions) >= 1<<12 {
+			t.Fatalf("loader %d has too many regions (%d)", idx, len(tl.Regions))
+		}
+		hdr.Info = uint16(len(tl.Regions)) << 4
+
+		var body []byte // everything after the header
+		off := func() uint16 { return uint16(ldrSize + len(body)) }
+		hdr.PathOffset = off()
+		body = align(append(append(body, tl.Path...), 0))
+		if tl.AltPath != "" {
+			hdr.AltPathOffset = off()
+			body = align(append(append(body, tl.AltPath...), 0))
+		}
+		if len(tl.Dependents) > 0 {
+			hdr.DepCount = uint16(len(tl.Dependents))
+			hdr.DependentLoaderRefsArrayOffset = off()
+			for _, ref := range tl.Dependents {
+				body = binary.LittleEndian.AppendUint16(body, uint16(ref))
+			}
+			body = align(body)
+		}
+		if tl.Kinds != nil {
+			if len(tl.Kinds) != len(tl.Dependents) {
+				t.Fatalf("loader %d has %d dependent kinds for %d dependents", idx, len(tl.Kinds), len(tl.Dependents))
+			}
+			hdr.DependentKindArrayOffset = off()
+			for _, kind := range tl.Kinds {
+				body = append(body, byte(kind))
+			}
+			body = align(body)
+		}
+		if len(tl.Regions) > 0 {
+			hdr.RegionsOffset = off()
+			for _, r := range tl.Regions {
+				body = binary.LittleEndian.AppendUint64(body, r.Info)
+				body = binary.LittleEndian.AppendUint32(body, r.FileOffset)
+				body = binary.LittleEndian.AppendUint32(body, r.FileSize)
+			}
+		}
+		if len(tl.BindTargets) > 0 {
+			hdr.BindTargetRefsOffset = off()
+			hdr.BindTargetRefsCount = uint32(len(tl.BindTargets))
+			for _, bt := range tl.BindTargets {
+				body = binary.LittleEndian.AppendUint64(body, uint64(bt))
+			}
+		}
+		if ldrSize+len(body) > 0xffff {
+			t.Fatalf("loader %d is too large (%#x bytes) for its 16-bit offsets", idx, ldrSize+len(body))
+		}
+
+		buf := new(bytes.Buffer)
+		if err := binary.Write(buf, binary.LittleEndian, hdr); err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(body)
+		blobs = append(blobs, align(buf.Bytes()))
+	}
+
+	buf := new(bytes.Buffer)
+	loadersOff := hdrSize
+	ldrOff := loadersOff + uint32(len(loaders)*4)
+	ldrOff += (8 - ldrOff%8) % 8
+	binary.Write(buf, binary.LittleEndian, PrebuiltLoaderSetHeader{
+		Magic:              PrebuiltLoaderSetMagic,
+		LoadersArrayCount:  uint32(len(loaders)),
+		LoadersArrayOffset: loadersOff,
+	})
+	for _, blob := range blobs {
+		binary.Write(buf, binary.LittleEndian, ldrOff)
+		ldrOff += uint32(len(blob))
+	}
+	for buf.Len()%8 != 0 {
+		buf.WriteByte(0)
+	}
+	for _, blob := range blobs {
+		buf.Write(blob)
+	}
+	return buf.Bytes()
+}
+
+func TestBuildTestLoaderSet(t *testing.T) {
+	maxOffset := int64(1<<38 - 1) // the largest positive low39 offset
+	data := buildTestLoaderSet(t,
+		testLoader{
+			Path:       "/Applications/Foo.app/Foo",
+			Dependents: []LoaderRef{NewLoaderRef(1, true), NewLoaderRef(0, false), NewMissingWeakImageRef()},
+			Kinds:      []DependentKind{KindNormal, KindReexport, KindWeakLink},
+			Regions:    []Region{{Info: 0x4000, FileOffset: 0x4000, FileSize: 0x1000}},
+			BindTargets: []BindTargetRef{
+				NewBindTargetRef(NewLoaderRef(0, false), maxOffset),
+				NewBindTargetRef(NewMissingWeakImageRef(), 0),
+			},
+			VmSize: 0x8000,
+		},
+		testLoader{Path: "/Applications/Foo.app/Frameworks/Bar.framework/Bar", AltPath: "@rpath/Bar.framework/Bar"},
+	)
+
+	pset, err := ParseLoaderSetAt(bytes.NewReader(data), 0, []*CacheImage{{Name: "/usr/lib/libSystem.B.dylib"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pset.Loaders) != 2 {
+		t.Fatalf("got %d loaders, want 2", len(pset.Loaders))
+	}
+
+	main := pset.Loaders[0]
+	if main.Path != "/Applications/Foo.app/Foo" || main.VmSize != 0x8000 {
+		t.Errorf("loader[0] = %q (vm_size %#x)", main.Path, main.VmSize)
+	}
+	if got, want := main.DependentKindCounts(), map[DependentKind]int{KindNormal: 1, KindReexport: 1, KindWeakLink: 1}; len(got) != len(want) ||
+		got[KindNormal] != 1 || got[KindReexport] != 1 || got[KindWeakLink] != 1 {
+		t.Errorf("dependent kinds = %v, want %v", got, want)
+	}
+	if main.Dependents[0].Name != "/Applications/Foo.app/Frameworks/Bar.framework/Bar" || main.Dependents[1].Name != "/usr/lib/libSystem.B.dylib" {
+		t.Errorf("dependents = %v", main.Dependents)
+	}
+	if !main.DependentRefs[2].IsMissingWeakImage() {
+		t.Errorf("dependent[2] = %s, want a missing weak image", main.DependentRefs[2])
+	}
+	if len(main.Regions) != 1 || main.Regions[0].FileOffset != 0x4000 || main.Regions[0].FileSize != 0x1000 {
+		t.Errorf("regions = %v", main.Regions)
+	}
+	if len(main.BindTargets) != 2 || main.BindTargets[0].Offset() != uint64(maxOffset) || !main.BindTargets[1].LoaderRef().IsMissingWeakImage() {
+		t.Errorf("bind targets = %v", main.BindTargets)
+	}
+
+	fw := pset.Loaders[1]
+	if fw.AltPath != "@rpath/Bar.framework/Bar" || len(fw.Dependents) != 0 || len(fw.Regions) != 0 || len(fw.BindTargets) != 0 {
+		t.Errorf("loader[1] = %q (alt %q) with %d dependents, %d regions and %d bind targets, want an empty loader",
+			fw.Path, fw.AltPath, len(fw.Dependents), len(fw.Regions), len(fw.BindTargets))
+	}
+
+	// zero loaders
+	pset, err = ParseLoaderSetAt(bytes.NewReader(buildTestLoaderSet(t)), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pset.Loaders) != 0 {
+		t.Errorf("got %d loaders, want 0", len(pset.Loaders))
+	}
+}
+
+func TestPrebuiltLoaderOverlappingRegions(t *testing.T) {
+	const zeroFill = 1 << 62
+	pl := PrebuiltLoader{
+		prebuiltLoaderHeader: prebuiltLoaderHeader{VmSize: 0x10000, IndexOfTwin: NoUnzipperedTwin},
+		Regions: []Region{
+			{Info: 0x0000, FileOffset: 0x0000, FileSize: 0x4000},
+			{Info: 0x4000, FileOffset: 0x4000, FileSize: 0x2000},
+			{Info: 0x5000, FileOffset: 0x6000, FileSize: 0x1000}, // overlaps region 1
+			{Info: 0x8000 | zeroFill, FileSize: 0x0000},          // zero-fill up to region 4
+			{Info: 0xc000, FileOffset: 0x7000, FileSize: 0x1000}, // adjacent, NOT overlapping
+		},
+	}
+	if got, want := pl.OverlappingRegions(), [][2]int{{1, 2}}; !slices.Equal(got, want) {
+		t.Errorf("OverlappingRegions() = %v, want %v", got, want)
+	}
+
+	pls := PrebuiltLoaderSet{Loaders: []PrebuiltLoader{pl}}
+	pls.Loaders[0].Ref = NewLoaderRef(0, true)
+	pls.Loaders[0].Path = "/usr/bin/foo"
+	errs := pls.Validate(&File{})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "region[1] overlaps region[2]") {
+		t.Errorf("Validate() = %v, want a single region overlap error", errs)
+	}
+}
+
+func TestPrebuiltLoaderDump(t *testing.T) {
+	data := buildTestLoaderSet(t,
+		testLoader{Path: "/usr/bin/foo", Dependents: []LoaderRef{NewLoaderRef(0, false)}, VmSize: 0x4000},
+		testLoader{Path: "/usr/lib/libfoo.dylib"},
+	)
+	f := &File{ByteOrder: binary.LittleEndian, Images: []*CacheImage{{Name: "/usr/lib/libSystem.B.dylib"}}}
+	pset, err := f.parseLoaderSetAt(bytes.NewReader(data), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ldrSize := binary.Size(prebuiltLoaderHeader{})
+	out := pset.Loaders[0].Dump(f)
+	for _, want := range []string{
+		fmt.Sprintf("PathOffset:                     %#x\n", ldrSize),
+		"DepCount:                       1\n",
+		"VmSize:                         0x4000\n",
+		"Size:                           0x",
+		"Path:    /usr/bin/foo\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Dump() is missing %q:\n%s", want, out)
+		}
+	}
+	// the last loader's size is unknown without the set's length
+	if out := pset.Loaders[1].Dump(f); !strings.Contains(out, "Size:                           unknown\n") {
+		t.Errorf("Dump() of the last loader should have an unknown size:\n%s", out)
+	}
+}
+
+func TestBindTargetRefStringMissingWeakImage(t *testing.T) {
+	// a cache with more images than the missing weak sentinel index must NOT name the sentinel after one of them
+	f := &File{Images: make([]*CacheImage, 0x8000)}
+	for idx := range f.Images {
+		f.Images[idx] = &CacheImage{Name: fmt.Sprintf("/usr/lib/lib%d.dylib", idx)}
+	}
+	for _, images := range [][]*CacheImage{nil, f.Images} {
+		bt := NewBindTargetRef(NewMissingWeakImageRef(), 0)
+		if got, want := bt.StringWithImages(images, nil), "0x00000000: (missing weak image)"; got != want {
+			t.Errorf("StringWithImages(%d images) = %q, want %q", len(images), got, want)
+		}
+	}
+	if got := NewBindTargetRef(NewMissingWeakImageRef(), 0x10).String(f); !strings.Contains(got, "missing weak image") {
+		t.Errorf("String() = %q, want a missing weak image", got)
+	}
+}
+
+func TestReadProgramTrie(t *testing.T) {
+	paths := []string{"/usr/bin/foo", "/usr/bin/bar"}
+	f, err := newSingleFileCache(bytes.NewReader(buildTestCache(t, paths...)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets, uuid, err := f.readProgramTrie()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uuid != f.UUID {
+		t.Errorf("trie UUID = %s, want %s", uuid, f.UUID)
+	}
+	psetSize := uint64(binary.Size(PrebuiltLoaderSetHeader{}))
+	if len(offsets) != 2 || offsets["/usr/bin/foo"] != 0 || offsets["/usr/bin/bar"] != psetSize {
+		t.Errorf("readProgramTrie() = %v, want foo at 0 and bar at %#x", offsets, psetSize)
+	}
+	// the single-path lookup must agree with the full walk
+	addr, err := f.getLaunchLoaderSetAddr("/usr/bin/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := f.Headers[f.UUID].ProgramsPblSetPoolAddr + psetSize; addr != want {
+		t.Errorf("getLaunchLoaderSetAddr() = %#x, want %#x", addr, want)
+	}
+
+	f.Headers[f.UUID] = CacheHeader{} // no ProgramTrie
+	if _, _, err := f.readProgramTrie(); !errors.Is(err, ErrPrebuiltLoaderSetNotSupported) {
+		t.Errorf("readProgramTrie() error = %v, want ErrPrebuiltLoaderSetNotSupported", err)
+	}
+}
+
+func TestCacheOptStats(t *testing.T) {
+	var stats CacheOptStats
+	stats.Add(&PrebuiltLoaderSet{
+		PrebuiltLoaderSetHeader: PrebuiltLoaderSetHeader{ObjcSelectorHashTableOffset: 0x100},
+		SelectorTable:           &ObjCSelectorOpt{Offsets: make([]BindTargetRef, 8)},
+		ClassTable:              &ObjCClassOpt{Offsets: make([]BindTargetRef, 4)},
+		SwiftTypeProtocolTable:  make(SwiftTypeConformanceEntries, 3),
+	})
+	stats.Add(&PrebuiltLoaderSet{}) // no optimizations
+	stats.Add(&PrebuiltLoaderSet{
+		PrebuiltLoaderSetHeader:       PrebuiltLoaderSetHeader{SwiftForeignTypeConformanceTableOffset: 0x200},
+		ProtocolTable:                 &ObjCClassOpt{Offsets: make([]BindTargetRef, 2)},
+		SwiftForeignTypeProtocolTable: make(SwiftForeignTypeConformanceEntries, 1),
+	})
+	want := CacheOptStats{
+		Closures:                     3,
+		OptimizedClosures:            2,
+		Selectors:                    8,
+		Classes:                      4,
+		Protocols:                    2,
+		SwiftTypeConformances:        3,
+		SwiftForeignTypeConformances: 1,
+	}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+	if stats.Total() != 18 {
+		t.Errorf("Total() = %d, want 18", stats.Total())
+	}
+}
+
+func TestPrebuiltLoaderSetIsExtension(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/Applications/Foo.app/PlugIns/FooWidget.appex/FooWidget", true},
+		{"/System/Library/ExtensionKit/Extensions/Bar.appex/Bar", true},
+		{"/Applications/Foo.app/Foo", false},
+		{"/usr/libexec/foo.appexd", false},
+	}
+	for _, tt := range tests {
+		pls := PrebuiltLoaderSet{Loaders: []PrebuiltLoader{{
+			prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Ref: NewLoaderRef(0, true)}},
+			Path:                 tt.path,
+		}}}
+		if got := pls.IsExtension(); got != tt.want {
+			t.Errorf("IsExtension(%s) = %t, want %t", tt.path, got, tt.want)
+		}
+	}
+	if (&PrebuiltLoaderSet{}).IsExtension() {
+		t.Error("IsExtension() of a set without a main executable should be false")
+	}
+}
+
+func TestPrebuiltLoaderSetMatchesCache(t *testing.T) {
+	cacheUUID := types.UUID{0xca, 0xfe, 0xba, 0xbe}
+
+	data := buildTestLoaderSet(t, testLoader{Path: "/usr/bin/foo"})
+	binary.LittleEndian.PutUint32(data[unsafe.Offsetof(PrebuiltLoaderSetHeader{}.DyldCacheUuidOffset):], uint32(len(data)))
+	data = append(data, cacheUUID[:]...)
+
+	pset, err := ParseLoaderSetAt(bytes.NewReader(data), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pset.DyldCacheUUID != cacheUUID {
+		t.Fatalf("DyldCacheUUID = %s, want %s", pset.DyldCacheUUID, cacheUUID)
+	}
+	if !pset.MatchesCache(&File{UUID: cacheUUID}) {
+		t.Error("MatchesCache() = false for the cache the set was built against")
+	}
+	if pset.MatchesCache(&File{UUID: types.UUID{0xde, 0xad}}) {
+		t.Error("MatchesCache() = true for a different cache")
+	}
+	if (&PrebuiltLoaderSet{}).MatchesCache(&File{}) {
+		t.Error("MatchesCache() = true for a set without a recorded cache UUID")
+	}
+}
+
+func TestPrebuiltLoaderPatchesByKind(t *testing.T) {
+	pl := PrebuiltLoader{DylibPatches: []DylibPatch{
+		{OverrideOffsetOfImpl: 0x1000, Kind: DylibPatchKindObjCClass},
+		{OverrideOffsetOfImpl: 0x2000, Kind: DylibPatchKindSingleton},
+		{OverrideOffsetOfImpl: 0x3000, Kind: DylibPatchKindObjCClass},
+		{Kind: DylibPatchKindMissingWeakImport},
+		{Kind: DylibPatchKindEndOfTable},
+	}}
+	got := pl.PatchesByKind()
+	if len(got) != 3 {
+		t.Fatalf("PatchesByKind() has %d kinds, want 3: %v", len(got), got)
+	}
+	if classes := got[DylibPatchKindObjCClass]; len(classes) != 2 || classes[0].OverrideOffsetOfImpl != 0x1000 || classes[1].OverrideOffsetOfImpl != 0x3000 {
+		t.Errorf("objc-class patches = %v", classes)
+	}
+	if len(got[DylibPatchKindSingleton]) != 1 || len(got[DylibPatchKindMissingWeakImport]) != 1 {
+		t.Errorf("PatchesByKind() = %v", got)
+	}
+	if _, ok := got[DylibPatchKindEndOfTable]; ok {
+		t.Error("PatchesByKind() should drop the end of table marker")
+	}
+}
+
+func TestPrebuiltLoaderSetBindDensity(t *testing.T) {
+	pls := PrebuiltLoaderSet{Loaders: []PrebuiltLoader{
+		{Path: "/light", prebuiltLoaderHeader: prebuiltLoaderHeader{VmSize: 0x10000}, BindTargets: make([]BindTargetRef, 16)}, // 0.25/KB
+		{Path: "/heavy", prebuiltLoaderHeader: prebuiltLoaderHeader{VmSize: 0x1000}, BindTargets: make([]BindTargetRef, 16)},  // 4/KB
+		{Path: "/jit"}, // unknown VM size
+	}}
+	pls.Loaders[0].OverrideBindTargets = make([]BindTargetRef, 2)
+
+	stats := pls.BindDensity()
+	var got []string
+	for _, s := range stats {
+		got = append(got, s.Path)
+	}
+	if want := []string{"/heavy", "/light", "/jit"}; !slices.Equal(got, want) {
+		t.Fatalf("BindDensity() order = %q, want %q", got, want)
+	}
+	if stats[0].BindsPerKB != 4 || stats[1].BindsPerKB != 0.25 || stats[1].OverrideCount != 2 || stats[2].BindsPerKB != 0 {
+		t.Errorf("BindDensity() = %v", stats)
+	}
+
+	var buf bytes.Buffer
+	if err := pls.WriteCSVByBindDensity(&buf, &File{}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[1], "/heavy,") || !strings.HasSuffix(lines[1], ",4.00") {
+		t.Errorf("WriteCSVByBindDensity() =\n%s", buf.String())
+	}
+
+	// has_swift comes from the closure's objc fixups and a nil cache is fine
+	pls.Loaders[1].ObjcFixupInfo = &ObjCBinaryInfo{HasClassStableSwiftFixups: true}
+	buf.Reset()
+	if err := pls.WriteCSV(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	col := slices.Index(rows[0], "has_swift")
+	if len(rows) != 4 || col < 0 || rows[1][col] != "false" || rows[2][col] != "true" || rows[3][col] != "false" {
+		t.Errorf("WriteCSV() has_swift = %v", rows)
+	}
+}
+
+func TestOpenClosureFile(t *testing.T) {
+	data := buildTestLoaderSet(t, testLoader{Path: "/usr/bin/foo", Dependents: []LoaderRef{NewLoaderRef(0, false)}})
+	binary.LittleEndian.PutUint32(data[unsafe.Offsetof(PrebuiltLoaderSetHeader{}.Length):], uint32(len(data)))
+	images := []*CacheImage{{Name: "/usr/lib/libSystem.B.dylib"}}
+
+	dir := t.TempDir()
+	bare := filepath.Join(dir, "foo.closure")
+	if err := os.WriteFile(bare, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wrapped := filepath.Join(dir, "foo.wrapped")
+	if err := os.WriteFile(wrapped, append(append(make([]byte, 0x40), data...), make([]byte, 0x10)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{bare, wrapped} {
+		pset, err := OpenClosureFile(path, images)
+		if err != nil {
+			t.Fatalf("OpenClosureFile(%s) error = %v", filepath.Base(path), err)
+		}
+		if len(pset.Loaders) != 1 || pset.Loaders[0].Path != "/usr/bin/foo" || pset.Loaders[0].Dependents[0].Name != "/usr/lib/libSystem.B.dylib" {
+			t.Errorf("OpenClosureFile(%s) = %+v", filepath.Base(path), pset.Loaders)
+		}
+	}
+
+	junk := filepath.Join(dir, "junk")
+	if err := os.WriteFile(junk, make([]byte, 0x100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var merr *MagicMismatchError
+	if _, err := OpenClosureFile(junk, nil); !errors.As(err, &merr) {
+		t.Errorf("OpenClosureFile(junk) error = %v, want a MagicMismatchError", err)
+	}
+}
+
+func TestLoaderBytes(t *testing.T) {
+	const base = 0x180000000
+	dat := buildTestCache(t, "/usr/bin/foo")
+	f, err := newSingleFileCache(bytes.NewReader(dat), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// replace the empty closure with one that has loaders
+	set := buildTestLoaderSet(t, testLoader{Path: "/usr/bin/foo"}, testLoader{Path: "/usr/lib/libfoo.dylib"})
+	binary.LittleEndian.PutUint32(set[unsafe.Offsetof(PrebuiltLoaderSetHeader{}.Length):], uint32(len(set)))
+	poolOff := f.Headers[f.UUID].ProgramsPblSetPoolAddr - base
+	if int(poolOff)+len(set) > len(dat) {
+		t.Fatal("test closure does not fit in the test cache")
+	}
+	copy(dat[poolOff:], set)
+
+	pset, err := f.GetLaunchLoaderSet("/usr/bin/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaderOffsets := []uint32{
+		binary.LittleEndian.Uint32(set[binary.Size(PrebuiltLoaderSetHeader{}):]),
+		binary.LittleEndian.Uint32(set[binary.Size(PrebuiltLoaderSetHeader{})+4:]),
+	}
+	for idx, want := range [][]byte{set[loaderOffsets[0]:loaderOffsets[1]], set[loaderOffsets[1]:]} {
+		got, err := f.LoaderBytes(&pset.Loaders[idx])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("LoaderBytes(loader[%d]) = %x, want %x", idx, got, want)
+		}
+	}
+
+	// the pool ends before the last loader does
+	hdr := f.Headers[f.UUID]
+	hdr.ProgramsPblSetPoolSize = uint64(loaderOffsets[1]) + 8
+	f.Headers[f.UUID] = hdr
+	var rerr *OffsetRangeError
+	if _, err := f.LoaderBytes(&pset.Loaders[1]); !errors.As(err, &rerr) {
+		t.Errorf("LoaderBytes() error = %v, want an OffsetRangeError", err)
+	}
+
+	standalone, err := ParseLoaderSetAt(bytes.NewReader(set), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.LoaderBytes(&standalone.Loaders[0]); err == nil {
+		t.Error("LoaderBytes() of a loader NOT parsed from the cache should fail")
+	}
+}
+
+func TestParseUnknownLoaderMagic(t *testing.T) {
+	data := buildTestLoaderSet(t, testLoader{Path: "/usr/bin/foo"}, testLoader{Path: "/usr/lib/libfoo.dylib"})
+	ldr1Off := binary.LittleEndian.Uint32(data[binary.Size(PrebuiltLoaderSetHeader{})+4:])
+	binary.LittleEndian.PutUint32(data[ldr1Off:], 0x6c357964) // "l5yd" (a future loader format)
+
+	// strict by default
+	var merr *MagicMismatchError
+	if _, err := ParseLoaderSetAt(bytes.NewReader(data), 0, nil); !errors.As(err, &merr) || merr.Got != 0x6c357964 {
+		t.Fatalf("ParseLoaderSetAt() error = %v, want a MagicMismatchError", err)
+	}
+
+	f := &File{ByteOrder: binary.LittleEndian, TolerateUnknownLoaders: true}
+	var stats ParseStats
+	pset, err := f.parsePrebuiltLoaderSet(io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))), 0, &stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pset.Loaders) != 2 || pset.Loaders[0].Unknown || pset.Loaders[0].Path != "/usr/bin/foo" {
+		t.Fatalf("loaders = %+v, want a parsed loader and a placeholder", pset.Loaders)
+	}
+	if unk := pset.Loaders[1]; !unk.Unknown || unk.Magic != 0x6c357964 || unk.Ref != NewLoaderRef(1, true) {
+		t.Errorf("loader[1] = %s (unknown=%t), want an unknown placeholder with the raw header", unk.Loader, unk.Unknown)
+	}
+	if stats.UnknownLoaders != 1 {
+		t.Errorf("stats.UnknownLoaders = %d, want 1", stats.UnknownLoaders)
+	}
+	if got := pset.Loaders[1].String(f); !strings.Contains(got, "Unknown loader (magic 0x6c357964)") {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestParseLegacyLoaderSetHeader(t *testing.T) {
+	hdrSize := binary.Size(PrebuiltLoaderSetHeader{})
+	paths := []string{"/usr/bin/foo", "/usr/lib/libfoo.dylib", "/usr/lib/libbar.dylib"}
+	var loaders []testLoader
+	for _, path := range paths {
+		loaders = append(loaders, testLoader{Path: path})
+	}
+	current := buildTestLoaderSet(t, loaders...)
+
+	// the same set with the older (shorter) header: the loaders array directly follows the Swift-less header
+	shift := uint32(hdrSize) - legacyLoaderSetHeaderSize
+	legacy := append(slices.Clone(current[:legacyLoaderSetHeaderSize]), current[hdrSize:]...)
+	binary.LittleEndian.PutUint32(legacy[unsafe.Offsetof(PrebuiltLoaderSetHeader{}.LoadersArrayOffset):], legacyLoaderSetHeaderSize)
+	for idx := range paths {
+		off := legacyLoaderSetHeaderSize + uint32(idx*4)
+		binary.LittleEndian.PutUint32(legacy[off:], binary.LittleEndian.Uint32(legacy[off:])-shift)
+	}
+
+	for name, data := range map[string][]byte{"current": current, "legacy": legacy} {
+		pset, err := ParseLoaderSetAt(bytes.NewReader(data), 0, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if pset.LoaderCount() != len(paths) || len(pset.Loaders) != len(paths) {
+			t.Errorf("%s: got %d loaders (header says %d), want %d", name, len(pset.Loaders), pset.LoaderCount(), len(paths))
+		}
+		for idx, path := range paths {
+			if idx < len(pset.Loaders) && pset.Loaders[idx].Path != path {
+				t.Errorf("%s: loader[%d] = %q, want %q", name, idx, pset.Loaders[idx].Path, path)
+			}
+		}
+		if pset.HasOptimizedSwift() {
+			t.Errorf("%s: loader offsets were misread as Swift table offsets: %+v", name, pset.PrebuiltLoaderSetHeader)
+		}
+	}
+	if got := (PrebuiltLoaderSetHeader{LoadersArrayCount: 1, LoadersArrayOffset: legacyLoaderSetHeaderSize}).HeaderSize(); got != legacyLoaderSetHeaderSize {
+		t.Errorf("HeaderSize() = %d, want %d", got, legacyLoaderSetHeaderSize)
+	}
+}
+
+func TestParseMustBeMissingPathsBounds(t *testing.T) {
+	base := buildTestLoaderSet(t, testLoader{Path: "/usr/bin/foo"})
+	withPaths := func(count uint32, paths string, hdr func(data []byte, pathsOff uint32)) []byte {
+		data := append(slices.Clone(base), paths...)
+		pathsOff := uint32(len(base))
+		binary.LittleEndian.PutUint32(data[unsafe.Offsetof(PrebuiltLoaderSetHeader{}.MustBeMissingPathsCount):], count)
+		binary.LittleEndian.PutUint32(data[unsafe.Offsetof(PrebuiltLoaderSetHeader{}.MustBeMissingPathsOffset):], pathsOff)
+		if hdr != nil {
+			hdr(data, pathsOff)
+		}
+		return data
+	}
+
+	pset, err := ParseLoaderSetAt(bytes.NewReader(withPaths(2, "/a\x00/b\x00", nil)), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pset.MustBeMissingPaths, []string{"/a", "/b"}) {
+		t.Errorf("MustBeMissingPaths = %q", pset.MustBeMissingPaths)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		idx  int
+	}{
+		{"truncated", withPaths(3, "/a\x00/b\x00/c", nil), 2},
+		{"count past the set's length", withPaths(3, "/a\x00/b\x00/c\x00", func(data []byte, pathsOff uint32) {
+			binary.LittleEndian.PutUint32(data[unsafe.Offsetof(PrebuiltLoaderSetHeader{}.Length):], pathsOff+6)
+		}), 2},
+		{"count past the next section", withPaths(2, "/a\x00/b\x00", func(data []byte, pathsOff uint32) {
+			binary.LittleEndian.PutUint32(data[unsafe.Offsetof(PrebuiltLoaderSetHeader{}.ObjcSelectorHashTableOffset):], pathsOff+3)
+		}), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseLoaderSetAt(bytes.NewReader(tt.data), 0, nil)
+			var merr *MalformedEntryError
+			if !errors.As(err, &merr) || merr.Index != tt.idx || !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("ParseLoaderSetAt() error = %v, want a MalformedEntryError for entry %d", err, tt.idx)
+			}
+		})
+	}
+}
+
+func TestPrebuiltLoaderSetValidateDuplicatePaths(t *testing.T) {
+	ldr := func(idx uint16, path string) PrebuiltLoader {
+		return PrebuiltLoader{
+			prebuiltLoaderHeader: prebuiltLoaderHeader{Loader: Loader{Ref: NewLoaderRef(idx, true)}, IndexOfTwin: NoUnzipperedTwin},
+			Path:                 path,
+		}
+	}
+	pset := PrebuiltLoaderSet{Loaders: []PrebuiltLoader{
+		ldr(0, "/usr/bin/foo"),
+		ldr(1, "/usr/lib/libfoo.dylib"),
+		ldr(2, ""), // e.g. a JIT placeholder
+		ldr(3, ""),
+		ldr(4, "/usr/lib/libfoo.dylib"),
+	}}
+	pset.Loaders[0].DependentRefs = []LoaderRef{NewLoaderRef(1, true), NewLoaderRef(2, true), NewLoaderRef(3, true), NewLoaderRef(4, true)}
+
+	if got, want := pset.duplicatePaths(), [][2]int{{1, 4}}; !slices.Equal(got, want) {
+		t.Errorf("duplicatePaths() = %v, want %v", got, want)
+	}
+	errs := pset.Validate(&File{})
+	if len(errs) != 1 || errs[0].Error() != "loader[4] /usr/lib/libfoo.dylib: has the same path as loader[1]" {
+		t.Errorf("Validate() = %v, want a single duplicate path error", errs)
+	}
+}
+
+func TestForEachLaunchLoaderSetParallel(t *testing.T) {
+	paths := []string{"/a", "/b", "/c", "/d", "/e"}
+	f, err := newSingleFileCache(bytes.NewReader(buildTestCache(t, paths...)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string]uint32)
+	if err := f.ForEachLaunchLoaderSetParallel(context.Background(), 3, func(execPath string, pset *PrebuiltLoaderSet) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[execPath] = pset.VersionHash
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != len(paths) {
+		t.Fatalf("walked %v, want %q", seen, paths)
+	}
+	for idx, path := range paths {
+		if seen[path] != uint32(idx) { // the test cache's closures have their trie index as VersionHash
+			t.Errorf("closure of %s has version %d, want %d", path, seen[path], idx)
+		}
+	}
+
+	errStop := errors.New("stop")
+	if err := f.ForEachLaunchLoaderSetParallel(context.Background(), 2, func(execPath string, pset *PrebuiltLoaderSet) error {
+		if execPath == "/b" {
+			return errStop
+		}
+		return nil
+	}); !errors.Is(err, errStop) {
+		t.Errorf("ForEachLaunchLoaderSetParallel() error = %v, want the handler's error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f.ForEachLaunchLoaderSetParallel(ctx, 2, func(string, *PrebuiltLoaderSet) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("ForEachLaunchLoaderSetParallel() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestPrebuiltLoaderSetConformancesForType(t *testing.T) {
+	const (
+		dupHead  = NextNode(1 << 0)
+		dupEntry = NextNode(1 << 1)
+		dupTail  = NextNode(1 << 2)
+	)
+	next := func(kind NextNode, idx uint32) NextNode { return kind | NextNode(idx<<3) }
+	foo := NewBindTargetRef(NewLoaderRef(1, false), 0x100)
+	bar := NewBindTargetRef(NewLoaderRef(1, false), 0x200)
+	hashable := NewBindTargetRef(NewLoaderRef(2, false), 0x10)
+	equatable := NewBindTargetRef(NewLoaderRef(2, false), 0x20)
+	typeEntry := func(typ, proto BindTargetRef, conformance BindTargetRef, nn NextNode) SwiftTypeProtocolNodeEntryT {
+		return SwiftTypeProtocolNodeEntryT{
+			Key:   SwiftTypeProtocolConformanceDiskLocationKey{TypeDescriptor: typ, Protocol: proto},
+			Value: SwiftTypeProtocolConformanceDiskLocation{ProtocolConformance: conformance},
+			Next:  nn,
+		}
+	}
+	pset := PrebuiltLoaderSet{
+		SwiftTypeProtocolTable: SwiftTypeConformanceEntries{
+			// two duplicate chains: Bar:Hashable (0 -> 1) and Foo:Equatable (2 -> 3 -> 4)
+			typeEntry(bar, hashable, 0x1000, next(dupHead, 1)),
+			typeEntry(bar, hashable, 0x1100, next(dupTail, 0)),
+			typeEntry(foo, equatable, 0x2000, next(dupHead, 3)),
+			typeEntry(foo, equatable, 0x2100, next(dupEntry, 4)),
+			typeEntry(foo, equatable, 0x2200, next(dupTail, 0)),
+			typeEntry(foo, hashable, 0x3000, 0),
+		},
+		SwiftForeignTypeProtocolTable: SwiftForeignTypeConformanceEntries{
+			{Key: SwiftForeignTypeProtocolConformanceDiskLocationKey{ForeignDescriptor: foo, Protocol: equatable}, Value: SwiftForeignTypeProtocolConformanceDiskLocation{ProtocolConformance: 0x4000}},
+		},
+		SwiftTypeNames: map[BindTargetRef]string{foo: "Foo", bar: "Bar"},
+	}
+
+	got := pset.ConformancesForType("Foo")
+	want := []SwiftConformance{
+		{Kind: SwiftConformanceType, TypeName: "Foo", Type: foo, Protocol: equatable, Conformance: 0x2000},
+		{Kind: SwiftConformanceType, TypeName: "Foo", Type: foo, Protocol: equatable, Conformance: 0x2100},
+		{Kind: SwiftConformanceType, TypeName: "Foo", Type: foo, Protocol: equatable, Conformance: 0x2200},
+		{Kind: SwiftConformanceType, TypeName: "Foo", Type: foo, Protocol: hashable, Conformance: 0x3000},
+		{Kind: SwiftConformanceForeignType, TypeName: "Foo", Type: foo, Protocol: equatable, Conformance: 0x4000},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ConformancesForType(Foo) = %v, want %v", got, want)
+	}
+	got = pset.ConformancesForType("Bar")
+	want = []SwiftConformance{
+		{Kind: SwiftConformanceType, TypeName: "Bar", Type: bar, Protocol: hashable, Conformance: 0x1000},
+		{Kind: SwiftConformanceType, TypeName: "Bar", Type: bar, Protocol: hashable, Conformance: 0x1100},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ConformancesForType(Bar) = %v, want %v", got, want)
+	}
+	if got := pset.ConformancesForType("Baz"); len(got) != 0 {
+		t.Errorf("ConformancesForType(Baz) = %v, want none", got)
+	}
+}
+
+func TestPrebuiltLoaderSetStringFiltered(t *testing.T) {
+	f := &File{ByteOrder: binary.LittleEndian}
+	pset, err := ParseLoaderSetAt(bytes.NewReader(buildTestLoaderSet(t,
+		testLoader{Path: "/usr/bin/foo"},
+		testLoader{Path: "/usr/lib/libfoo.dylib"},
+		testLoader{Path: "/usr/lib/libbar.dylib"},
+	)), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := pset.String(f)
+	for _, pl := range pset.Loaders {
+		if !strings.Contains(all, pl.Path) {
+			t.Errorf("String() is missing %s", pl.Path)
+		}
+	}
+	got := pset.StringFiltered(f, func(pl *PrebuiltLoader) bool { return strings.Contains(pl.Path, "libfoo") })
+	if !strings.Contains(got, "/usr/lib/libfoo.dylib") || strings.Contains(got, "Path:    /usr/bin/foo\n") || strings.Contains(got, "libbar") {
+		t.Errorf("StringFiltered() = %q, want ONLY libfoo", got)
+	}
+	if !strings.Contains(got, "Loaders: (1 of 3)") {
+		t.Errorf("StringFiltered() = %q, want the filtered loader count", got)
+	}
+}
+
+// recordingDriver is a database/sql driver that records the statements executed through it
+type recordingDriver struct {
+	mu        sync.Mutex
+	execs     []recordedExec
+	prepared  int
+	committed bool
+}
+
+type recordedExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.prepared++
+	return recordingStmt{c.d, query}, nil
+}
+func (c recordingConn) Close() error              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) { return recordingTx{c.d}, nil }
+
+type recordingTx struct{ d *recordingDriver }
+
+func (tx recordingTx) Commit() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+	tx.d.committed = true
+	return nil
+}
+func (tx recordingTx) Rollback() error { return nil }
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+func (s recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.execs = append(s.d.execs, recordedExec{s.query, args})
+	return driver.RowsAffected(1), nil
+}
+func (s recordingStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("recordingStmt: Query is not supported")
+}
+
+// rows returns the args of every recorded insert into table
+func (d *recordingDriver) rows(table string) [][]driver.Value {
+	var rows [][]driver.Value
+	for _, e := range d.execs {
+		if strings.HasPrefix(e.query, "INSERT INTO "+table+" ") {
+			rows = append(rows, e.args)
+		}
+	}
+	return rows
+}
+
+func TestExportClosuresToSQLite(t *testing.T) {
+	rec := &recordingDriver{}
+	sql.Register("dyld-test-recording", rec)
+	defer func(name string) { SQLiteDriverName = name }(SQLiteDriverName)
+	SQLiteDriverName = "dyld-test-recording"
+
+	pset := buildTestLoaderSet(t,
+		testLoader{
+			Path:        "/usr/bin/foo",
+			Dependents:  []LoaderRef{NewLoaderRef(1, true)},
+			Regions:     []Region{{Info: 0x4000 | 5<<59, FileOffset: 0x4000, FileSize: 0x1000}},
+			BindTargets: []BindTargetRef{NewBindTargetRef(NewLoaderRef(1, true), -8)},
+			VmSize:      0x8000,
+		},
+		testLoader{Path: "/usr/lib/libfoo.dylib"},
+	)
+	f, err := newSingleFileCache(bytes.NewReader(buildTestCacheWithSets(t, []string{"/usr/bin/foo"}, [][]byte{pset})), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.ExportClosuresToSQLite("unused.db"); err != nil {
+		t.Fatal(err)
+	}
+	if !rec.committed {
+		t.Error("export was not committed")
+	}
+	if rec.prepared < len(closureTables)+5 {
+		t.Errorf("prepared %d statements, want the inserts prepared once each", rec.prepared)
+	}
+
+	loaders := rec.rows("loaders")
+	if len(loaders) != 2 || loaders[0][2] != "/usr/bin/foo" || loaders[1][2] != "/usr/lib/libfoo.dylib" || loaders[0][7] != int64(0x8000) {
+		t.Errorf("loaders = %v, want foo and libfoo", loaders)
+	}
+	if deps := rec.rows("dependents"); len(deps) != 1 || deps[0][2] != "/usr/lib/libfoo.dylib" {
+		t.Errorf("dependents = %v, want foo -> libfoo", deps)
+	}
+	if binds := rec.rows("bind_targets"); len(binds) != 1 || binds[0][4] != "/usr/lib/libfoo.dylib" || binds[0][5] != int64(-8) {
+		t.Errorf("bind_targets = %v, want libfoo-8", binds)
+	}
+	if regions := rec.rows("regions"); len(regions) != 1 || regions[0][3] != int64(0x4000) || regions[0][6] != "r-x" {
+		t.Errorf("regions = %v, want a r-x region at 0x4000", regions)
+	}
+}
+
+func TestClosuresContainingAddress(t *testing.T) {
+	const libfoo = 0x180100000
+	images := []*CacheImage{
+		{Name: "/usr/lib/libSystem.B.dylib", Index: 0, CacheImageTextInfo: CacheImageTextInfo{LoadAddress: 0x180000000, TextSegmentSize: 0x4000}},
+		{Name: "/usr/lib/libfoo.dylib", Index: 1, CacheImageTextInfo: CacheImageTextInfo{LoadAddress: libfoo, TextSegmentSize: 0x4000}},
+	}
+	dependsOn := buildTestLoaderSet(t, testLoader{Path: "/usr/bin/dep", Dependents: []LoaderRef{NewLoaderRef(0, false), NewLoaderRef(1, false)}})
+	bindsTo := buildTestLoaderSet(t, testLoader{Path: "/usr/bin/bind", BindTargets: []BindTargetRef{NewBindTargetRef(NewLoaderRef(1, false), 0x10)}})
+	neither := buildTestLoaderSet(t, testLoader{
+		Path:        "/usr/bin/other",
+		Dependents:  []LoaderRef{NewLoaderRef(0, false)},
+		BindTargets: []BindTargetRef{NewAbsoluteBindTargetRef(libfoo)},
+	})
+	dat := buildTestCacheWithSets(t, []string{"/usr/bin/dep", "/usr/bin/bind", "/usr/bin/other"}, [][]byte{dependsOn, bindsTo, neither})
+	f, err := newSingleFileCache(bytes.NewReader(dat), images)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := f.ClosuresContainingAddress(context.Background(), libfoo+0x100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/usr/bin/dep", "/usr/bin/bind"}; !slices.Equal(got, want) {
+		t.Errorf("ClosuresContainingAddress(libfoo) = %q, want %q", got, want)
+	}
+	got, err = f.ClosuresContainingAddress(context.Background(), 0x180000100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/usr/bin/dep", "/usr/bin/other"}; !slices.Equal(got, want) {
+		t.Errorf("ClosuresContainingAddress(libSystem) = %q, want %q", got, want)
+	}
+}
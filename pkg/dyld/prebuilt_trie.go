@@ -0,0 +1,162 @@
+package dyld
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// walkProgramTrie walks the ProgramTrie stored in r (of the given size) one node at a time and calls
+// handler with every executable path and its offset into the ProgramsPblSetPool.
+// NOTE: unlike trie.ParseTrie this does NOT read the whole trie into memory (only a small buffer per node)
+func walkProgramTrie(r io.ReaderAt, size int64, handler func(execPath string, poolOffset uint64) error) error {
+	type node struct {
+		offset uint64
+		prefix string
+	}
+
+	br := bufio.NewReaderSize(nil, 512)
+	visited := make(map[uint64]bool)
+	stack := []node{{offset: 0}}
+
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if n.offset >= uint64(size) {
+			return fmt.Errorf("trie node offset %#x is outside of the trie (size %#x)", n.offset, size)
+		}
+		if visited[n.offset] {
+			return fmt.Errorf("trie node at offset %#x is visited more than once (loop in trie)", n.offset)
+		}
+		visited[n.offset] = true
+
+		br.Reset(io.NewSectionReader(r, int64(n.offset), size-int64(n.offset)))
+
+		terminalSize, err := binary.ReadUvarint(br)
+		if err != nil {
+			return fmt.Errorf("failed to read terminalSize of trie node at offset %#x: %v", n.offset, err)
+		}
+		if terminalSize != 0 {
+			poolOffset, err := binary.ReadUvarint(br)
+			if err != nil {
+				return fmt.Errorf("failed to read value of trie node at offset %#x: %v", n.offset, err)
+			}
+			if err := handler(n.prefix, poolOffset); err != nil {
+				return err
+			}
+			// skip whatever is left of the terminal info
+			next := int64(n.offset) + int64(uvarintLen(terminalSize)) + int64(terminalSize)
+			if next >= size {
+				return fmt.Errorf("trie node at offset %#x has terminal info past the end of the trie", n.offset)
+			}
+			br.Reset(io.NewSectionReader(r, next, size-next))
+		}
+
+		childCount, err := br.ReadByte()
+		if err != nil {
+			return fmt.Errorf("failed to read child count of trie node at offset %#x: %v", n.offset, err)
+		}
+
+		children := make([]node, 0, childCount)
+		for i := 0; i < int(childCount); i++ {
+			edge, err := br.ReadString('\x00')
+			if err != nil {
+				return fmt.Errorf("failed to read edge string of trie node at offset %#x: %v", n.offset, err)
+			}
+			childOffset, err := binary.ReadUvarint(br)
+			if err != nil {
+				return fmt.Errorf("failed to read child offset of trie node at offset %#x: %v", n.offset, err)
+			}
+			children = append(children, node{
+				offset: childOffset,
+				prefix: n.prefix + edge[:len(edge)-1],
+			})
+		}
+		// push in reverse so children are visited in trie order
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, children[i])
+		}
+	}
+
+	return nil
+}
+
+// lookupProgramTrie follows the single path of execPath through the ProgramTrie stored in r (of the given size)
+// and returns its offset into the ProgramsPblSetPool.
+// NOTE: like walkProgramTrie this only reads the nodes on the path (a small buffer per node), NOT the whole trie
+func lookupProgramTrie(r io.ReaderAt, size int64, execPath string) (uint64, error) {
+	br := bufio.NewReaderSize(nil, 512)
+	visited := make(map[uint64]bool)
+
+	var offset uint64
+	rest := execPath
+	for {
+		if offset >= uint64(size) {
+			return 0, fmt.Errorf("trie node offset %#x is outside of the trie (size %#x)", offset, size)
+		}
+		if visited[offset] {
+			return 0, fmt.Errorf("trie node at offset %#x is visited more than once (loop in trie)", offset)
+		}
+		visited[offset] = true
+
+		br.Reset(io.NewSectionReader(r, int64(offset), size-int64(offset)))
+
+		terminalSize, err := binary.ReadUvarint(br)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read terminalSize of trie node at offset %#x: %v", offset, err)
+		}
+		if len(rest) == 0 {
+			if terminalSize == 0 {
+				return 0, fmt.Errorf("trie node at offset %#x has no terminal info", offset)
+			}
+			poolOffset, err := binary.ReadUvarint(br)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read value of trie node at offset %#x: %v", offset, err)
+			}
+			return poolOffset, nil
+		}
+		if terminalSize != 0 {
+			next := int64(offset) + int64(uvarintLen(terminalSize)) + int64(terminalSize)
+			if next >= size {
+				return 0, fmt.Errorf("trie node at offset %#x has terminal info past the end of the trie", offset)
+			}
+			br.Reset(io.NewSectionReader(r, next, size-next))
+		}
+
+		childCount, err := br.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read child count of trie node at offset %#x: %v", offset, err)
+		}
+		var found bool
+		for i := 0; i < int(childCount) && !found; i++ {
+			edge, err := br.ReadString('\x00')
+			if err != nil {
+				return 0, fmt.Errorf("failed to read edge string of trie node at offset %#x: %v", offset, err)
+			}
+			childOffset, err := binary.ReadUvarint(br)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read child offset of trie node at offset %#x: %v", offset, err)
+			}
+			if edge = edge[:len(edge)-1]; len(edge) > 0 && strings.HasPrefix(rest, edge) {
+				rest = rest[len(edge):]
+				offset = childOffset
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("no edge of trie node at offset %#x matches %q", offset, rest)
+		}
+	}
+}
+
+func uvarintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
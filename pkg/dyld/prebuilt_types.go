@@ -1,11 +1,22 @@
 package dyld
 
 import (
-	"crypto/sha1"
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"slices"
 	"strings"
+	"time"
+	"unsafe"
 
 	"github.com/blacktop/go-macho/types"
+	"github.com/blacktop/go-macho/types/objc"
 	"github.com/olekukonko/tablewriter"
 )
 
@@ -16,20 +27,47 @@ const (
 )
 
 var ErrPrebuiltLoaderSetNotSupported = fmt.Errorf("dyld_shared_cache has no launch prebuilt loader set info")
+var ErrJITLoader = fmt.Errorf("loader is a JustInTimeLoader (not a PrebuiltLoader)")
+var ErrBindTargetNotInCache = fmt.Errorf("bind target is not in the dyld_shared_cache")
+var ErrLoaderNotInCache = fmt.Errorf("loader is not in the dyld_shared_cache")
+
+// bitfield returns nbits bits of the packed field v starting at bit start
+func bitfield[T ~uint16 | ~uint32 | ~uint64](v T, start, nbits int32) uint64 {
+	return types.ExtractBits(uint64(v), start, nbits)
+}
+
+// bitflag returns true if the single bit of the packed field v at bit start is set
+func bitflag[T ~uint16 | ~uint32 | ~uint64](v T, start int32) bool {
+	return bitfield(v, start, 1) != 0
+}
 
 type LoaderRef uint16
 
 // index       : 15,   // index into PrebuiltLoaderSet
 // app         :  1;   // app vs dyld cache PrebuiltLoaderSet
 
+// NewLoaderRef returns a LoaderRef for the given index into a PrebuiltLoaderSet (app) or the dyld cache
+func NewLoaderRef(index uint16, isApp bool) LoaderRef {
+	ref := LoaderRef(index & 0x7fff)
+	if isApp {
+		ref |= 0x8000
+	}
+	return ref
+}
+
+// NewMissingWeakImageRef returns the LoaderRef used for weak-linked images that are missing
+func NewMissingWeakImageRef() LoaderRef {
+	return NewLoaderRef(0x7fff, false)
+}
+
 // Index index into PrebuiltLoaderSet
 func (l LoaderRef) Index() uint16 {
-	return uint16(types.ExtractBits(uint64(l), 0, 15))
+	return uint16(bitfield(l, 0, 15))
 }
 
 // IsApp app vs dyld cache PrebuiltLoaderSet
 func (l LoaderRef) IsApp() bool {
-	return types.ExtractBits(uint64(l), 15, 1) != 0
+	return bitflag(l, 15)
 }
 func (l LoaderRef) IsMissingWeakImage() bool {
 	return (l.Index() == 0x7fff) && !l.IsApp()
@@ -46,6 +84,18 @@ func (l LoaderRef) String() string {
 	return fmt.Sprintf("index: %d%s%s", l.Index(), typ, mssing_weak_image)
 }
 
+func (l LoaderRef) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Index            uint16 `json:"index"`
+		IsApp            bool   `json:"isApp"`
+		MissingWeakImage bool   `json:"missingWeakImage"`
+	}{
+		Index:            l.Index(),
+		IsApp:            l.IsApp(),
+		MissingWeakImage: l.IsMissingWeakImage(),
+	})
+}
+
 type Loader struct {
 	Magic uint32 // "l4yd"
 	Info  uint16
@@ -64,37 +114,37 @@ type Loader struct {
 }
 
 func (l Loader) IsPrebuilt() bool {
-	return types.ExtractBits(uint64(l.Info), 0, 1) != 0
+	return bitflag(l.Info, 0)
 }
 func (l Loader) DylibInDyldCache() bool {
-	return types.ExtractBits(uint64(l.Info), 1, 1) != 0
+	return bitflag(l.Info, 1)
 }
 func (l Loader) HasObjC() bool {
-	return types.ExtractBits(uint64(l.Info), 2, 1) != 0
+	return bitflag(l.Info, 2)
 }
 func (l Loader) MayHavePlusLoad() bool {
-	return types.ExtractBits(uint64(l.Info), 3, 1) != 0
+	return bitflag(l.Info, 3)
 }
 func (l Loader) HasReadOnlyData() bool {
-	return types.ExtractBits(uint64(l.Info), 4, 1) != 0
+	return bitflag(l.Info, 4)
 }
 func (l Loader) NeverUnload() bool {
-	return types.ExtractBits(uint64(l.Info), 5, 1) != 0
+	return bitflag(l.Info, 5)
 }
 func (l Loader) LeaveMapped() bool {
-	return types.ExtractBits(uint64(l.Info), 6, 1) != 0
+	return bitflag(l.Info, 6)
 }
 func (l Loader) HasReadOnlyObjC() bool {
-	return types.ExtractBits(uint64(l.Info), 7, 1) != 0
+	return bitflag(l.Info, 7)
 }
 func (l Loader) Pre2022Binary() bool {
-	return types.ExtractBits(uint64(l.Info), 8, 1) != 0
+	return bitflag(l.Info, 8)
 }
 func (l Loader) IsPremapped() bool {
-	return types.ExtractBits(uint64(l.Info), 9, 1) != 0
+	return bitflag(l.Info, 9)
 }
 
-func (l Loader) String() string {
+func (l Loader) flags() []string {
 	var out []string
 	if l.IsPrebuilt() {
 		out = append(out, "prebuilt")
@@ -128,7 +178,11 @@ func (l Loader) String() string {
 	if l.IsPremapped() {
 		out = append(out, "premapped")
 	}
-	return fmt.Sprintf("%s, ref: %s", strings.Join(out, "|"), l.Ref)
+	return out
+}
+
+func (l Loader) String() string {
+	return fmt.Sprintf("%s, ref: %s", strings.Join(l.flags(), "|"), l.Ref)
 }
 
 type DependentKind uint8
@@ -157,20 +211,39 @@ func (k DependentKind) String() string {
 
 type BindTargetRef uint64
 
+// loaderRef : 16,
+// high8     :  8,
+// low39     : 39,  // signed
+// kind      :  1;  // 0 = regular (relative to loaderRef), 1 = absolute
+
+// NewBindTargetRef returns a BindTargetRef for the given offset into the image referenced by ref.
+// NOTE: only offsets whose bits 39-55 are a sign extension of bit 38 can be encoded (other bits are dropped)
+func NewBindTargetRef(ref LoaderRef, offset int64) BindTargetRef {
+	return BindTargetRef(uint64(ref) |
+		(uint64(offset)>>56)<<16 |
+		(uint64(offset)&(1<<39-1))<<24)
+}
+
+// NewAbsoluteBindTargetRef returns an absolute BindTargetRef for the given value.
+// NOTE: only values whose bit 63 matches bit 62 can be encoded (bit 63 holds the kind)
+func NewAbsoluteBindTargetRef(value uint64) BindTargetRef {
+	return BindTargetRef(1<<63 | value&(1<<63-1))
+}
+
 func (b BindTargetRef) LoaderRef() LoaderRef {
-	return LoaderRef(types.ExtractBits(uint64(b), 0, 16))
+	return LoaderRef(bitfield(b, 0, 16))
 }
 func (b BindTargetRef) high8() uint64 {
-	return types.ExtractBits(uint64(b), 16, 8)
+	return bitfield(b, 16, 8)
 }
 func (b BindTargetRef) low39() uint64 {
-	return types.ExtractBits(uint64(b), 24, 39) // signed
+	return bitfield(b, 24, 39) // signed
 }
 func (b BindTargetRef) AbsoluteValue() uint64 {
-	return deserializeAbsoluteValue(uint64(types.ExtractBits(uint64(b), 0, 63)))
+	return deserializeAbsoluteValue(bitfield(b, 0, 63))
 }
 func (b BindTargetRef) Kind() uint8 {
-	return uint8(types.ExtractBits(uint64(b), 63, 1))
+	return uint8(bitfield(b, 63, 1))
 }
 func (b BindTargetRef) IsAbsolute() bool {
 	return b.Kind() == 1
@@ -185,14 +258,66 @@ func (b BindTargetRef) Offset() uint64 {
 	}
 	return (b.high8() << 56) | signedOffset
 }
+
+// CacheAddress returns the dyld_shared_cache VM address the bind target points at:
+//   - cache dylib targets: the target image's load address + Offset()
+//   - absolute targets: the absolute value itself (it is NOT relative to any image)
+//
+// App loader targets (and missing weak images) are NOT in the cache and return ErrBindTargetNotInCache
+// (use Offset() to get the offset into the app loader's image instead)
+func (b BindTargetRef) CacheAddress(f *File) (uint64, error) {
+	if b.IsAbsolute() {
+		return b.Offset(), nil
+	}
+	if b.LoaderRef().IsApp() || b.LoaderRef().IsMissingWeakImage() {
+		return 0, fmt.Errorf("%w: %s", ErrBindTargetNotInCache, b.LoaderRef())
+	}
+	if int(b.LoaderRef().Index()) >= len(f.Images) {
+		return 0, &OffsetRangeError{Field: "bind target image index", Offset: uint64(b.LoaderRef().Index()), Limit: uint64(len(f.Images))}
+	}
+	return f.Images[b.LoaderRef().Index()].LoadAddress + b.Offset(), nil
+}
+
+// MarshalJSON emits the structural form of the bind target (use String to resolve image names)
+func (b BindTargetRef) MarshalJSON() ([]byte, error) {
+	var ref *LoaderRef
+	if !b.IsAbsolute() {
+		lr := b.LoaderRef()
+		ref = &lr
+	}
+	return json.Marshal(&struct {
+		IsAbsolute bool       `json:"isAbsolute"`
+		LoaderRef  *LoaderRef `json:"loaderRef,omitempty"`
+		Offset     string     `json:"offset"`
+	}{
+		IsAbsolute: b.IsAbsolute(),
+		LoaderRef:  ref,
+		Offset:     fmt.Sprintf("%#x", b.Offset()),
+	})
+}
+
 func (b BindTargetRef) String(f *File) string {
+	return b.StringWithImages(f.Images, nil)
+}
+
+// StringWithImages returns the bind target with its loader resolved from the given cache images and
+// (for app loader refs) the loaders of its PrebuiltLoaderSet, so that it can be used without a *File
+func (b BindTargetRef) StringWithImages(images []*CacheImage, loaders []PrebuiltLoader) string {
 	if b.IsAbsolute() {
 		return fmt.Sprintf("%#08x: (absolue)", b.Offset())
 	}
-	if b.LoaderRef().IsApp() || int(b.LoaderRef().Index()) >= len(f.Images) {
-		return fmt.Sprintf("%#08x: (%s)", b.Offset(), b.LoaderRef())
+	ref := b.LoaderRef()
+	if ref.IsMissingWeakImage() {
+		return fmt.Sprintf("%#08x: (missing weak image)", b.Offset())
 	}
-	return fmt.Sprintf("%#08x: %s", b.Offset(), f.Images[b.LoaderRef().Index()].Name)
+	if ref.IsApp() {
+		if int(ref.Index()) < len(loaders) {
+			return fmt.Sprintf("%#08x: %s", b.Offset(), loaders[ref.Index()].Path)
+		}
+	} else if int(ref.Index()) < len(images) {
+		return fmt.Sprintf("%#08x: %s", b.Offset(), images[ref.Index()].Name)
+	}
+	return fmt.Sprintf("%#08x: (%s)", b.Offset(), ref)
 }
 
 type CachePatch struct {
@@ -200,18 +325,81 @@ type CachePatch struct {
 	DylibVMOffset uint32
 	PatchTo       BindTargetRef
 }
-type dpkind int64
+
+// ResolvedCachePatch is a CachePatch with its dylib and replacement resolved to names
+type ResolvedCachePatch struct {
+	CachePatch
+	Dylib         string // the overridden cache dylib
+	ReplaceLoader string // the loader providing the replacement
+	ReplaceOffset uint64
+	Invalid       bool // the patch's dylib or replacement index is out of range
+}
+
+func (rp ResolvedCachePatch) String() string {
+	var invalid string
+	if rp.Invalid {
+		invalid = " (invalid)"
+	}
+	return fmt.Sprintf("%s+%#08x -> %s+%#08x%s", rp.Dylib, rp.DylibVMOffset, rp.ReplaceLoader, rp.ReplaceOffset, invalid)
+}
+
+// DylibPatchKind is the kind of a DylibPatch
+type DylibPatchKind int64
 
 const (
-	endOfPatchTable   dpkind = -1
-	missingWeakImport dpkind = 0
-	objcClass         dpkind = 1
-	singleton         dpkind = 2
+	DylibPatchKindEndOfTable        DylibPatchKind = -1 // marks the end of a loader's patch table
+	DylibPatchKindMissingWeakImport DylibPatchKind = 0  // the root does not implement the symbol
+	DylibPatchKindObjCClass         DylibPatchKind = 1  // the root implements the objc class
+	DylibPatchKindSingleton         DylibPatchKind = 2  // the root implements the singleton object
 )
 
+func (k DylibPatchKind) String() string {
+	switch k {
+	case DylibPatchKindEndOfTable:
+		return "end"
+	case DylibPatchKindMissingWeakImport:
+		return "missing-weak-import"
+	case DylibPatchKindObjCClass:
+		return "objc-class"
+	case DylibPatchKindSingleton:
+		return "singleton"
+	default:
+		return fmt.Sprintf("unknown %d", k)
+	}
+}
+
+// DylibPatch is an entry in an overriding (root) dylib's patch table; one per cache dylib export that the root replaces
 type DylibPatch struct {
 	OverrideOffsetOfImpl int64
-	Kind                 dpkind
+	Kind                 DylibPatchKind
+}
+
+func (dp DylibPatch) String() string {
+	switch dp.Kind {
+	case DylibPatchKindMissingWeakImport:
+		return fmt.Sprintf("%s: the root does not implement the symbol (uses are patched to NULL)", dp.Kind)
+	case DylibPatchKindObjCClass:
+		return fmt.Sprintf("%#08x: %s (uses of the cache class are patched to the root's class)", dp.OverrideOffsetOfImpl, dp.Kind)
+	case DylibPatchKindSingleton:
+		// singletons are objects (e.g. constant CF/NS objects) that must exist exactly once in the process, so every
+		// use in the cache is patched to the root's ONE instance instead of the cache's copy
+		return fmt.Sprintf("%#08x: %s (every cache use is patched to the root's single instance)", dp.OverrideOffsetOfImpl, dp.Kind)
+	default:
+		return fmt.Sprintf("%#08x: %s", dp.OverrideOffsetOfImpl, dp.Kind)
+	}
+}
+
+// PatchesByKind returns the loader's dylib patches grouped by kind (the end of table marker is dropped)
+// NOTE: a patch's position in DylibPatches is the index of the cache export it patches, which is NOT kept here
+func (pl *PrebuiltLoader) PatchesByKind() map[DylibPatchKind][]DylibPatch {
+	patches := make(map[DylibPatchKind][]DylibPatch)
+	for _, dp := range pl.DylibPatches {
+		if dp.Kind == DylibPatchKindEndOfTable {
+			break
+		}
+		patches[dp.Kind] = append(patches[dp.Kind], dp)
+	}
+	return patches
 }
 
 // Region stored in PrebuiltLoaders and generated on the fly by JustInTimeLoaders, passed to mapSegments()
@@ -226,19 +414,19 @@ type Region struct {
 }
 
 func (r Region) VMOffset() uint64 {
-	return types.ExtractBits(r.Info, 0, 59)
+	return bitfield(r.Info, 0, 59)
 }
 
 func (r Region) Perms() types.VmProtection {
-	return types.VmProtection(types.ExtractBits(r.Info, 59, 3))
+	return types.VmProtection(bitfield(r.Info, 59, 3))
 }
 
 func (r Region) IsZeroFill() bool {
-	return types.ExtractBits(r.Info, 62, 1) != 0
+	return bitflag(r.Info, 62)
 }
 
 func (r Region) ReadOnlyData() bool {
-	return types.ExtractBits(r.Info, 63, 1) != 0
+	return bitflag(r.Info, 63)
 }
 
 func (r Region) String() string {
@@ -251,6 +439,24 @@ func (r Region) String() string {
 		r.ReadOnlyData())
 }
 
+func (r Region) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		VMOffset     string `json:"vmOffset"`
+		FileOffset   string `json:"fileOffset"`
+		FileSize     string `json:"fileSize"`
+		Perms        string `json:"perms"`
+		IsZeroFill   bool   `json:"isZeroFill"`
+		ReadOnlyData bool   `json:"readOnlyData"`
+	}{
+		VMOffset:     fmt.Sprintf("%#x", r.VMOffset()),
+		FileOffset:   fmt.Sprintf("%#x", r.FileOffset),
+		FileSize:     fmt.Sprintf("%#x", r.FileSize),
+		Perms:        r.Perms().String(),
+		IsZeroFill:   r.IsZeroFill(),
+		ReadOnlyData: r.ReadOnlyData(),
+	})
+}
+
 type RSKind uint32
 
 const (
@@ -274,6 +480,7 @@ func (k RSKind) String() string {
 
 type ResolvedSymbol struct {
 	TargetLoader        *Loader
+	TargetLoaderPath    string
 	TargetSymbolName    string
 	TargetRuntimeOffset uint64
 	Kind                RSKind
@@ -282,6 +489,35 @@ type ResolvedSymbol struct {
 	IsMissingFlatLazy   bool
 }
 
+func (rs ResolvedSymbol) String() string {
+	if rs.Kind == RSKindBindAbsolute {
+		return fmt.Sprintf("%s %#x", rs.Kind, rs.TargetRuntimeOffset)
+	}
+	var out []string
+	out = append(out, rs.Kind.String())
+	if len(rs.TargetSymbolName) > 0 {
+		out = append(out, rs.TargetSymbolName)
+	}
+	var flags []string
+	if rs.IsWeakDef {
+		flags = append(flags, "weak-def")
+	}
+	if rs.IsCode {
+		flags = append(flags, "code")
+	}
+	if rs.IsMissingFlatLazy {
+		flags = append(flags, "missing-flat-lazy")
+	}
+	if len(flags) > 0 {
+		out = append(out, "("+strings.Join(flags, ", ")+")")
+	}
+	target := rs.TargetLoaderPath
+	if len(target) == 0 {
+		target = "<unknown>"
+	}
+	return fmt.Sprintf("%s -> %s+%#x", strings.Join(out, " "), target, rs.TargetRuntimeOffset)
+}
+
 type BindTarget struct {
 	Loader        *Loader
 	RuntimeOffset uint64
@@ -298,6 +534,23 @@ type fileValidation struct {
 	CheckCDHash     bool
 }
 
+// FileValidationRecord is a loader's file validation info along with the path of the binary it validates
+type FileValidationRecord struct {
+	Path            string
+	ExecPath        string // the (first) launch closure the record was found in
+	CDHash          string // empty if the CDHash is NOT checked
+	SliceOffset     uint64
+	DeviceID        uint64
+	Inode           uint64
+	Mtime           uint64
+	CheckInodeMtime bool
+}
+
+// CDHashString returns the hex encoded CDHash (the field already is the CDHash, it is NOT hashed again)
+func (fv *fileValidation) CDHashString() string {
+	return hex.EncodeToString(fv.CDHash[:])
+}
+
 type CodeSignatureInFile struct {
 	FileOffset uint32
 	Size       uint32
@@ -362,6 +615,9 @@ type prebuiltLoaderHeader struct {
 	//  bind targets
 }
 
+// objCBinaryInfoSize is the on-disk size of ObjCBinaryInfo
+const objCBinaryInfoSize = 76
+
 // ObjCBinaryInfo stores information about the layout of the objc sections in a binary,
 // as well as other properties relating to the objc information in there.
 type ObjCBinaryInfo struct {
@@ -417,30 +673,100 @@ func (o ObjCBinaryInfo) String() string {
 	out += fmt.Sprintf("  __objc_classlist: %#08x (count=%d)\n", o.ClassListRuntimeOffset, o.ClassListCount)
 	out += fmt.Sprintf("  __objc_catlist:   %#08x (count=%d)\n", o.CategoryListRuntimeOffset, o.CategoryCount)
 	out += fmt.Sprintf("  __objc_protolist: %#08x (count=%d)\n", o.ProtocolListRuntimeOffset, o.ProtocolListCount)
-	var flags []string
-	if o.HasClassStableSwiftFixups {
-		flags = append(flags, "class-stable-swift-fixups")
-	}
-	if o.HasClassMethodListsToSetUniqued {
-		flags = append(flags, "class-method-lists-to-set-uniqued")
-	}
-	if o.HasCategoryMethodListsToSetUniqued {
-		flags = append(flags, "category-method-lists-to-set-uniqued")
-	}
-	if o.HasProtocolMethodListsToSetUniqued {
-		flags = append(flags, "protocol-method-lists-to-set-uniqued")
+	flags := o.Flags().names()
+	if len(flags) > 0 {
+		out += "\n  flags:\n"
+		for _, f := range flags {
+			out += fmt.Sprintf("    - %s\n", f)
+		}
 	}
-	if o.HasClassMethodListsToUnique {
-		flags = append(flags, "class-method-lists-to-unique")
+	return out
+}
+
+// ObjCFixupFlags is the bitmask of an ObjCBinaryInfo's Has* flags
+type ObjCFixupFlags uint8
+
+const (
+	ObjCHasClassStableSwiftFixups ObjCFixupFlags = 1 << iota
+	ObjCHasClassMethodListsToSetUniqued
+	ObjCHasCategoryMethodListsToSetUniqued
+	ObjCHasProtocolMethodListsToSetUniqued
+	ObjCHasClassMethodListsToUnique
+	ObjCHasCategoryMethodListsToUnique
+	ObjCHasProtocolMethodListsToUnique
+)
+
+var objCFixupFlagNames = []string{
+	"class-stable-swift-fixups",
+	"class-method-lists-to-set-uniqued",
+	"category-method-lists-to-set-uniqued",
+	"protocol-method-lists-to-set-uniqued",
+	"class-method-lists-to-unique",
+	"category-method-lists-to-unique",
+	"protocol-method-lists-to-unique",
+}
+
+// Flags returns the ObjCBinaryInfo's Has* flags as a bitmask
+func (o ObjCBinaryInfo) Flags() ObjCFixupFlags {
+	var flags ObjCFixupFlags
+	for i, set := range []bool{
+		o.HasClassStableSwiftFixups,
+		o.HasClassMethodListsToSetUniqued,
+		o.HasCategoryMethodListsToSetUniqued,
+		o.HasProtocolMethodListsToSetUniqued,
+		o.HasClassMethodListsToUnique,
+		o.HasCategoryMethodListsToUnique,
+		o.HasProtocolMethodListsToUnique,
+	} {
+		if set {
+			flags |= 1 << i
+		}
 	}
-	if o.HasCategoryMethodListsToUnique {
-		flags = append(flags, "category-method-lists-to-unique")
+	return flags
+}
+
+// Has returns true if ALL of the given flags are set
+func (f ObjCFixupFlags) Has(flags ObjCFixupFlags) bool {
+	return f&flags == flags
+}
+
+func (f ObjCFixupFlags) names() []string {
+	var names []string
+	for i, name := range objCFixupFlagNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
 	}
-	if o.HasProtocolMethodListsToUnique {
-		flags = append(flags, "protocol-method-lists-to-unique")
+	return names
+}
+
+func (f ObjCFixupFlags) String() string {
+	return strings.Join(f.names(), "|")
+}
+
+// ObjCImageInfoData is a loader's objc_image_info (found at ObjCBinaryInfo.ImageInfoRuntimeOffset)
+type ObjCImageInfoData struct {
+	objc.ImageInfo
+}
+
+// SwiftStableABIVersion returns the stable Swift ABI version (0 if the image has no Swift)
+func (i ObjCImageInfoData) SwiftStableABIVersion() uint16 {
+	return uint16((i.Flags & objc.SwiftStableVersionMask) >> objc.SwiftStableVersionMaskShift)
+}
+
+// SwiftUnstableABIVersion returns the (pre-stable) Swift ABI version
+func (i ObjCImageInfoData) SwiftUnstableABIVersion() uint8 {
+	return uint8((i.Flags & objc.SwiftUnstableVersionMask) >> objc.SwiftUnstableVersionMaskShift)
+}
+
+func (i ObjCImageInfoData) String() string {
+	var out string
+	out += fmt.Sprintf("  version: %d\n", i.Version)
+	if i.HasSwift() {
+		out += fmt.Sprintf("  swift:   %s (stable_abi=%d, unstable_abi=%d)\n", i.Flags.SwiftVersion(), i.SwiftStableABIVersion(), i.SwiftUnstableABIVersion())
 	}
-	if len(flags) > 0 {
-		out += "\n  flags:\n"
+	if flags := i.Flags.List(); len(flags) > 0 {
+		out += "  flags:\n"
 		for _, f := range flags {
 			out += fmt.Sprintf("    - %s\n", f)
 		}
@@ -454,30 +780,50 @@ type PrebuiltLoader struct {
 	AltPath                     string
 	Twin                        string
 	Dependents                  []dependent
+	DependentRefs               []LoaderRef
 	FileValidation              *fileValidation
 	Regions                     []Region
 	BindTargets                 []BindTargetRef
 	DylibPatches                []DylibPatch
 	OverrideBindTargets         []BindTargetRef
+	OverrideBindTargetNames     []string // loader paths resolved from OverrideBindTargets ("" if unresolved)
 	ObjcFixupInfo               *ObjCBinaryInfo
 	ObjcCanonicalProtocolFixups []bool
 	ObjcSelectorFixups          []BindTargetRef
+	ObjcSelectorFixupNames      []string // selector strings resolved from ObjcSelectorFixups ("" if unresolved, nil if NOT resolved; see File.ResolveClosureNames)
+	Unknown                     bool     // the loader has an unknown magic (see File.TolerateUnknownLoaders) and ONLY its raw Loader header is set
+
+	size uint32 // the loader's size in its set computed from the loader offsets (0 if unknown)
+	addr uint64 // the loader's (unslid) cache address (0 if its set was NOT parsed from the cache)
 }
 
 func (pl PrebuiltLoader) HasInitializers() bool {
-	return types.ExtractBits(uint64(pl.Info), 0, 1) != 0
+	return bitflag(pl.Info, 0)
 }
 func (pl PrebuiltLoader) IsOverridable() bool {
-	return types.ExtractBits(uint64(pl.Info), 1, 1) != 0
+	return bitflag(pl.Info, 1)
 }
 func (pl PrebuiltLoader) SupportsCatalyst() bool {
-	return types.ExtractBits(uint64(pl.Info), 2, 1) != 0
+	return bitflag(pl.Info, 2)
 }
 func (pl PrebuiltLoader) IsCatalystOverride() bool {
-	return types.ExtractBits(uint64(pl.Info), 3, 1) != 0
+	return bitflag(pl.Info, 3)
 }
 func (pl PrebuiltLoader) RegionsCount() uint16 {
-	return uint16(types.ExtractBits(uint64(pl.Info), 4, 12))
+	return uint16(bitfield(pl.Info, 4, 12))
+}
+
+// IsUnzipperedTwin returns true if the loader is one half of an unzippered twin (macOS/Catalyst)
+func (pl *PrebuiltLoader) IsUnzipperedTwin() bool {
+	return pl.IndexOfTwin != NoUnzipperedTwin
+}
+
+// TwinIndex returns the cache image index of the loader's unzippered twin
+func (pl *PrebuiltLoader) TwinIndex() (uint16, bool) {
+	if !pl.IsUnzipperedTwin() {
+		return 0, false
+	}
+	return pl.IndexOfTwin, true
 }
 func (pl PrebuiltLoader) GetInfo() string {
 	var out []string
@@ -495,24 +841,250 @@ func (pl PrebuiltLoader) GetInfo() string {
 	}
 	return strings.Join(out, "|")
 }
+
+// RegionVMEnd returns the end of the VM extent of the region at the given index.
+// NOTE: a region's FileSize can understate its VM extent (e.g. zero-fill and page padding),
+// so the extent runs up to the start of the next region (or the loader's VM size for the last region)
+func (pl PrebuiltLoader) RegionVMEnd(idx int) uint64 {
+	rg := pl.Regions[idx]
+	end := rg.VMOffset() + uint64(rg.FileSize)
+	next := uint64(pl.VmSize)
+	for _, other := range pl.Regions {
+		if other.VMOffset() > rg.VMOffset() && other.VMOffset() < next {
+			next = other.VMOffset()
+		}
+	}
+	if next > end {
+		return next
+	}
+	return end
+}
+
+// OverlappingRegions returns the index pairs (i < j) of the loader's regions whose VM ranges overlap.
+// A region covers [VMOffset, VMOffset+FileSize) (or its full VM extent for zero-fill regions);
+// a correctly-built loader has none and any overlap makes vmoffset to file offset translation ambiguous
+func (pl *PrebuiltLoader) OverlappingRegions() [][2]int {
+	extent := func(idx int) (uint64, uint64) {
+		rg := pl.Regions[idx]
+		if rg.IsZeroFill() {
+			return rg.VMOffset(), pl.RegionVMEnd(idx)
+		}
+		return rg.VMOffset(), rg.VMOffset() + uint64(rg.FileSize)
+	}
+	var overlaps [][2]int
+	for i := range pl.Regions {
+		iStart, iEnd := extent(i)
+		for j := i + 1; j < len(pl.Regions); j++ {
+			jStart, jEnd := extent(j)
+			if iStart < jEnd && jStart < iEnd {
+				overlaps = append(overlaps, [2]int{i, j})
+			}
+		}
+	}
+	return overlaps
+}
+
+// ReadOnlyDataRegions returns the indexes of the loader's regions that are marked read-only data (e.g. __DATA_CONST
+// which dyld makes read-only again once fixups are applied)
+func (pl *PrebuiltLoader) ReadOnlyDataRegions() []int {
+	var ro []int
+	for idx, rg := range pl.Regions {
+		if rg.ReadOnlyData() {
+			ro = append(ro, idx)
+		}
+	}
+	return ro
+}
+
+// ReadOnlyDataMismatch returns true if the loader's has-ro-data/has-ro-objc flags do NOT agree with its regions
+// (a flag set but no region marked read-only data, or a region marked read-only data with neither flag set)
+func (pl *PrebuiltLoader) ReadOnlyDataMismatch() bool {
+	return (pl.HasReadOnlyData() || pl.HasReadOnlyObjC()) != (len(pl.ReadOnlyDataRegions()) > 0)
+}
+
+// CoalescedRegions returns a copy of the loader's regions with adjacent regions that share the same
+// perms/zero-fill/ro-data flags merged together (for display ONLY; Regions is NOT modified).
+// NOTE: file backed regions are only merged when BOTH their VM and file ranges are contiguous
+func (pl *PrebuiltLoader) CoalescedRegions() []Region {
+	var out []Region
+	for _, rg := range pl.Regions {
+		if len(out) > 0 {
+			last := &out[len(out)-1]
+			if last.Perms() == rg.Perms() && last.IsZeroFill() == rg.IsZeroFill() && last.ReadOnlyData() == rg.ReadOnlyData() {
+				if rg.IsZeroFill() {
+					continue // zero-fill extents run up to the next region
+				}
+				if last.VMOffset()+uint64(last.FileSize) == rg.VMOffset() &&
+					uint64(last.FileOffset)+uint64(last.FileSize) == uint64(rg.FileOffset) {
+					last.FileSize += rg.FileSize
+					continue
+				}
+			}
+		}
+		out = append(out, rg)
+	}
+	return out
+}
+
+// GetFileOffset returns the file offset for a given VM offset (or 0 if it is not file backed)
 func (pl PrebuiltLoader) GetFileOffset(vmoffset uint64) uint64 {
-	for _, region := range pl.Regions {
-		if vmoffset >= region.VMOffset() && vmoffset < region.VMOffset()+uint64(region.FileSize) {
-			return uint64(region.FileOffset) + (vmoffset - region.VMOffset())
+	off, _, _ := pl.TranslateVMOffset(vmoffset)
+	return off
+}
+
+// ExportsTrieFileOffset returns the file offset of the loader's exports trie; the whole trie must be within one of the
+// loader's file backed regions (otherwise an ExportsTrieOffsetError is returned)
+func (pl *PrebuiltLoader) ExportsTrieFileOffset() (uint64, error) {
+	for idx, region := range pl.Regions {
+		start := region.VMOffset()
+		if pl.ExportsTrieLoaderOffset < start || pl.ExportsTrieLoaderOffset >= pl.RegionVMEnd(idx) {
+			continue
+		}
+		if region.IsZeroFill() || pl.ExportsTrieLoaderOffset+uint64(pl.ExportsTrieLoaderSize) > start+uint64(region.FileSize) {
+			break
+		}
+		return uint64(region.FileOffset) + (pl.ExportsTrieLoaderOffset - start), nil
+	}
+	return 0, &ExportsTrieOffsetError{Path: pl.Path, VMOffset: pl.ExportsTrieLoaderOffset, Size: pl.ExportsTrieLoaderSize}
+}
+
+// ReadExportsTrie reads the loader's exports trie from its (possibly fat) binary in r of the given size
+func (pl *PrebuiltLoader) ReadExportsTrie(r io.ReaderAt, size int64) ([]byte, error) {
+	if pl.ExportsTrieLoaderSize == 0 {
+		return nil, fmt.Errorf("%s has no exports trie", pl.Path)
+	}
+	off, err := pl.ExportsTrieFileOffset()
+	if err != nil {
+		return nil, err
+	}
+	sr := io.NewSectionReader(r, 0, size)
+	if pl.FileValidation != nil {
+		if sr, err = pl.FileValidation.sliceReader(r, size); err != nil {
+			return nil, err
+		}
+	}
+	if off+uint64(pl.ExportsTrieLoaderSize) > uint64(sr.Size()) {
+		return nil, &OffsetRangeError{Field: "exports trie end", Offset: off + uint64(pl.ExportsTrieLoaderSize) - 1, Limit: uint64(sr.Size())}
+	}
+	data := make([]byte, pl.ExportsTrieLoaderSize)
+	if _, err := sr.ReadAt(data, int64(off)); err != nil {
+		return nil, fmt.Errorf("failed to read exports trie of %s: %w", pl.Path, err)
+	}
+	return data, nil
+}
+
+// ReadObjCImageInfo reads the loader's objc_image_info from its on-disk (possibly fat) binary in r of the given size
+// (use File.ObjCImageInfo for in-cache dylibs)
+func (pl *PrebuiltLoader) ReadObjCImageInfo(r io.ReaderAt, size int64) (*ObjCImageInfoData, error) {
+	if pl.ObjcFixupInfo == nil || pl.ObjcFixupInfo.ImageInfoRuntimeOffset == 0 {
+		return nil, fmt.Errorf("loader %s has no __objc_imageinfo", pl.Path)
+	}
+	var info ObjCImageInfoData
+	off, zeroFill, ok := pl.TranslateVMOffset(pl.ObjcFixupInfo.ImageInfoRuntimeOffset)
+	if !ok || zeroFill {
+		return nil, fmt.Errorf("objc_image_info of %s at vm offset %#x is NOT within a file backed region", pl.Path, pl.ObjcFixupInfo.ImageInfoRuntimeOffset)
+	}
+	sr := io.NewSectionReader(r, 0, size)
+	if pl.FileValidation != nil {
+		var err error
+		if sr, err = pl.FileValidation.sliceReader(r, size); err != nil {
+			return nil, err
 		}
 	}
-	return 0
+	if err := binary.Read(io.NewSectionReader(sr, int64(off), int64(binary.Size(info.ImageInfo))), binary.LittleEndian, &info.ImageInfo); err != nil {
+		return nil, fmt.Errorf("failed to read objc_image_info of %s: %w", pl.Path, err)
+	}
+	return &info, nil
+}
+
+// TranslateVMOffset returns the file offset for a given VM offset; isZeroFill is true if the
+// VM offset is within a region but has no file backing (zero-fill), ok is false if no region contains it
+func (pl PrebuiltLoader) TranslateVMOffset(vmoffset uint64) (offset uint64, isZeroFill bool, ok bool) {
+	for idx, region := range pl.Regions {
+		if vmoffset >= region.VMOffset() && vmoffset < pl.RegionVMEnd(idx) {
+			if region.IsZeroFill() || vmoffset >= region.VMOffset()+uint64(region.FileSize) {
+				return 0, true, true
+			}
+			return uint64(region.FileOffset) + (vmoffset - region.VMOffset()), false, true
+		}
+	}
+	return 0, false, false
 }
 func (pl PrebuiltLoader) String(f *File) string {
+	return pl.string(f, false)
+}
+
+// StringVerbose is like String but also resolves the symbols of the loader's bind targets (slow)
+func (pl PrebuiltLoader) StringVerbose(f *File) string {
+	return pl.string(f, true)
+}
+
+// Dump returns every raw prebuiltLoaderHeader field (offsets are relative to the start of the loader)
+// followed by the decoded String view, to correlate with a hex dump of the closure
+func (pl *PrebuiltLoader) Dump(f *File) string {
+	var out strings.Builder
+	out.WriteString("Raw Header:\n")
+	field := func(name string, format string, v any) {
+		fmt.Fprintf(&out, "\t%-31s "+format+"\n", name+":", v)
+	}
+	field("Magic", "%#08x", pl.Magic)
+	field("Loader.Info", "%#04x", pl.Loader.Info)
+	field("Ref", "%#04x", uint16(pl.Ref))
+	field("PathOffset", "%#x", pl.PathOffset)
+	field("DependentLoaderRefsArrayOffset", "%#x", pl.DependentLoaderRefsArrayOffset)
+	field("DependentKindArrayOffset", "%#x", pl.DependentKindArrayOffset)
+	field("FixupsLoadCommandOffset", "%#x", pl.FixupsLoadCommandOffset)
+	field("AltPathOffset", "%#x", pl.AltPathOffset)
+	field("FileValidationOffset", "%#x", pl.FileValidationOffset)
+	field("Info", "%#04x", pl.prebuiltLoaderHeader.Info)
+	field("RegionsCount", "%d", pl.RegionsCount())
+	field("RegionsOffset", "%#x", pl.RegionsOffset)
+	field("DepCount", "%d", pl.DepCount)
+	field("BindTargetRefsOffset", "%#x", pl.BindTargetRefsOffset)
+	field("BindTargetRefsCount", "%d", pl.BindTargetRefsCount)
+	field("ObjcBinaryInfoOffset", "%#x", pl.ObjcBinaryInfoOffset)
+	field("IndexOfTwin", "%#x", pl.IndexOfTwin)
+	field("ExportsTrieLoaderOffset", "%#x", pl.ExportsTrieLoaderOffset)
+	field("ExportsTrieLoaderSize", "%#x", pl.ExportsTrieLoaderSize)
+	field("VmSize", "%#x", pl.VmSize)
+	field("CodeSignature.FileOffset", "%#x", pl.CodeSignature.FileOffset)
+	field("CodeSignature.Size", "%#x", pl.CodeSignature.Size)
+	field("PatchTableOffset", "%#x", pl.PatchTableOffset)
+	field("OverrideBindTargetRefsOffset", "%#x", pl.OverrideBindTargetRefsOffset)
+	field("OverrideBindTargetRefsCount", "%d", pl.OverrideBindTargetRefsCount)
+	if pl.size > 0 {
+		field("Size", "%#x", pl.size)
+	} else {
+		field("Size", "%s", "unknown")
+	}
+	out.WriteString("\n")
+	out.WriteString(pl.String(f))
+	return out.String()
+}
+
+func (pl PrebuiltLoader) string(f *File, verbose bool) string {
 	var out string
+	if pl.Unknown {
+		return fmt.Sprintf("Unknown loader (magic %#08x)\n", pl.Magic)
+	}
 	if pl.Path != "" {
 		out += fmt.Sprintf("Path:    %s\n", pl.Path)
 	}
 	if pl.AltPath != "" {
 		out += fmt.Sprintf("AltPath: %s\n", pl.AltPath)
 	}
-	if pl.Twin != "" {
-		out += fmt.Sprintf("Twin:    %s\n", pl.Twin)
+	if pl.IsUnzipperedTwin() {
+		twin := pl.Twin
+		if twin == "" {
+			twin = fmt.Sprintf("(index=%d)", pl.IndexOfTwin)
+		}
+		if pl.IsCatalystOverride() {
+			out += fmt.Sprintf("Twin:    %s (this is the catalyst side, twin is the macOS side)\n", twin)
+		} else if pl.SupportsCatalyst() {
+			out += fmt.Sprintf("Twin:    %s (this is the macOS side, twin is the catalyst side)\n", twin)
+		} else {
+			out += fmt.Sprintf("Twin:    %s\n", twin)
+		}
 	}
 	out += fmt.Sprintf("VM Size:       %#x\n", pl.VmSize)
 	if pl.CodeSignature.Size > 0 {
@@ -520,9 +1092,7 @@ func (pl PrebuiltLoader) String(f *File) string {
 	}
 	if pl.FileValidation != nil {
 		if pl.FileValidation.CheckCDHash {
-			h := sha1.New()
-			h.Write(pl.FileValidation.CDHash[:])
-			out += fmt.Sprintf("CDHash:        %x\n", h.Sum(nil))
+			out += fmt.Sprintf("CDHash:        %s\n", pl.FileValidation.CDHashString())
 		}
 		if pl.FileValidation.CheckInodeMtime {
 			out += fmt.Sprintf("slice-offset:  %#x\n", pl.FileValidation.SliceOffset)
@@ -539,7 +1109,11 @@ func (pl PrebuiltLoader) String(f *File) string {
 		out += fmt.Sprintf("Info:          %s\n", pl.GetInfo())
 	}
 	if pl.ExportsTrieLoaderSize > 0 {
-		out += fmt.Sprintf("ExportsTrie:   off=%#08x, sz=%#x\n", pl.GetFileOffset(pl.ExportsTrieLoaderOffset), pl.ExportsTrieLoaderSize)
+		if off, err := pl.ExportsTrieFileOffset(); err == nil {
+			out += fmt.Sprintf("ExportsTrie:   off=%#08x, sz=%#x\n", off, pl.ExportsTrieLoaderSize)
+		} else {
+			out += fmt.Sprintf("ExportsTrie:   vm_off=%#08x, sz=%#x (NOT in a file backed region)\n", pl.ExportsTrieLoaderOffset, pl.ExportsTrieLoaderSize)
+		}
 	}
 	if pl.FixupsLoadCommandOffset > 0 {
 		out += fmt.Sprintf("FixupsLoadCmd: off=%#08x\n", pl.FixupsLoadCommandOffset)
@@ -573,15 +1147,72 @@ func (pl PrebuiltLoader) String(f *File) string {
 			out += fmt.Sprintf("\t%-10s) %s\n", dp.Kind, dp.Name)
 		}
 	}
+	var resolver *bindTargetResolver
+	if verbose {
+		resolver = newBindTargetResolver(f)
+	}
 	if len(pl.BindTargets) > 0 {
 		out += "\nBindTargets:\n"
-		for _, bt := range pl.BindTargets {
-			out += fmt.Sprintf("  %s\n", bt.String(f))
+		tableString := &strings.Builder{}
+		bdata := [][]string{}
+		for idx, bt := range pl.BindTargets {
+			kind := RSKindBindToImage
+			image := bt.LoaderRef().String()
+			if bt.IsAbsolute() {
+				kind = RSKindBindAbsolute
+				image = ""
+			} else if bt.LoaderRef() == pl.Ref {
+				kind = RSKindRebase
+				image = pl.Path
+			} else if !bt.LoaderRef().IsApp() && !bt.LoaderRef().IsMissingWeakImage() && int(bt.LoaderRef().Index()) < len(f.Images) {
+				image = f.Images[bt.LoaderRef().Index()].Name
+			}
+			var symbol string
+			if resolver != nil && kind == RSKindBindToImage {
+				if rs, err := resolver.resolve(bt); err == nil {
+					symbol = rs.TargetSymbolName
+				}
+			}
+			bdata = append(bdata, []string{
+				fmt.Sprintf("%d", idx),
+				kind.String(),
+				image,
+				symbol,
+				fmt.Sprintf("%#08x", bt.Offset()),
+			})
+		}
+		table := tablewriter.NewWriter(tableString)
+		table.SetHeader([]string{"Index", "Kind", "Image", "Symbol", "Offset"})
+		table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+		table.SetCenterSeparator("|")
+		table.AppendBulk(bdata)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.Render()
+		out += tableString.String()
+	}
+	if len(pl.DylibPatches) > 0 && pl.DylibPatches[0].Kind != DylibPatchKindEndOfTable {
+		out += "\nDylib Patches:\n"
+		for idx, dp := range pl.DylibPatches {
+			if dp.Kind == DylibPatchKindEndOfTable {
+				break
+			}
+			out += fmt.Sprintf("  %s", dp)
+			// NOTE: the root is on disk, so the singleton is named by the export of the overridden cache dylib it replaces
+			if verbose && dp.Kind == DylibPatchKindSingleton {
+				if name, err := f.DylibPatchExportName(&pl, idx); err == nil && len(name) > 0 {
+					out += fmt.Sprintf(" %s", name)
+				}
+			}
+			out += "\n"
 		}
 	}
 	if len(pl.OverrideBindTargets) > 0 {
 		out += "\nOverride BindTargets:\n"
-		for _, bt := range pl.OverrideBindTargets {
+		for idx, bt := range pl.OverrideBindTargets {
+			if idx < len(pl.OverrideBindTargetNames) && pl.OverrideBindTargetNames[idx] != "" {
+				out += fmt.Sprintf("  %#08x: %s\n", bt.Offset(), pl.OverrideBindTargetNames[idx])
+				continue
+			}
 			out += fmt.Sprintf("  %s\n", bt.String(f))
 		}
 	}
@@ -589,6 +1220,16 @@ func (pl PrebuiltLoader) String(f *File) string {
 		out += "\nObjC Fixup Info:\n"
 		out += fmt.Sprintln(pl.ObjcFixupInfo.String())
 	}
+	if pl.ObjcFixupInfo != nil && pl.ObjcFixupInfo.ImageInfoRuntimeOffset != 0 {
+		if info, err := f.ObjCImageInfo(&pl); err == nil {
+			out += "ObjC Image Info:\n"
+			out += fmt.Sprintln(info.String())
+		} else if errors.Is(err, ErrLoaderNotInCache) {
+			out += fmt.Sprintf("ObjC Image Info: in the on-disk binary at vm_off=%#x\n", pl.ObjcFixupInfo.ImageInfoRuntimeOffset)
+		} else {
+			out += fmt.Sprintf("ObjC Image Info: %v\n", err)
+		}
+	}
 	if len(pl.ObjcCanonicalProtocolFixups) > 0 {
 		out += "ObjC Canonical ProtocolFixups:\n"
 		for _, fixup := range pl.ObjcCanonicalProtocolFixups {
@@ -597,7 +1238,11 @@ func (pl PrebuiltLoader) String(f *File) string {
 	}
 	if len(pl.ObjcSelectorFixups) > 0 {
 		out += "\nObjC SelectorFixups:\n"
-		for _, bt := range pl.ObjcSelectorFixups {
+		for idx, bt := range pl.ObjcSelectorFixups {
+			if idx < len(pl.ObjcSelectorFixupNames) && pl.ObjcSelectorFixupNames[idx] != "" {
+				out += fmt.Sprintf("  selector %q -> %s\n", pl.ObjcSelectorFixupNames[idx], bt.String(f))
+				continue
+			}
 			out += fmt.Sprintf("  %s\n", bt.String(f))
 		}
 	}
@@ -605,9 +1250,8 @@ func (pl PrebuiltLoader) String(f *File) string {
 	return out
 }
 
-// PrebuiltLoaderSet is an mmap()ed read-only data structure which holds a set of PrebuiltLoader objects;
-// The contained PrebuiltLoader objects can be found be index O(1) or path O(n).
-type prebuiltLoaderSetHeader struct {
+// PrebuiltLoaderSetHeader is the fixed size header at the start of a PrebuiltLoaderSet
+type PrebuiltLoaderSetHeader struct {
 	Magic                    uint32
 	VersionHash              uint32 // PREBUILTLOADER_VERSION
 	Length                   uint32
@@ -630,8 +1274,77 @@ type prebuiltLoaderSetHeader struct {
 	SwiftForeignTypeConformanceTableOffset uint32
 }
 
+// legacyLoaderSetHeaderSize is the size of the PrebuiltLoaderSet header before the Swift conformance table offsets were added
+const legacyLoaderSetHeaderSize = uint32(unsafe.Offsetof(PrebuiltLoaderSetHeader{}.SwiftTypeConformanceTableOffset))
+
+// HeaderSize returns the size of the set's header layout.
+// NOTE: older dyld versions (with a different VersionHash) wrote a shorter header without the Swift conformance table
+// offsets. The version hashes themselves are opaque, so the layout is derived from where the loaders array starts
+// (it directly follows the header)
+func (h PrebuiltLoaderSetHeader) HeaderSize() uint32 {
+	if h.LoadersArrayCount > 0 && h.LoadersArrayOffset >= legacyLoaderSetHeaderSize && h.LoadersArrayOffset < uint32(binary.Size(h)) {
+		return legacyLoaderSetHeaderSize
+	}
+	return uint32(binary.Size(h))
+}
+
+// nextSectionOffset returns the offset of the first section of the set after off (or the end of the set);
+// 0 if there is no section after off and the set's length is unknown
+func (h PrebuiltLoaderSetHeader) nextSectionOffset(off uint32) uint32 {
+	var next uint32
+	for _, o := range []uint64{
+		uint64(h.Length),
+		uint64(h.LoadersArrayOffset),
+		uint64(h.CachePatchOffset),
+		uint64(h.DyldCacheUuidOffset),
+		uint64(h.MustBeMissingPathsOffset),
+		uint64(h.ObjcSelectorHashTableOffset),
+		uint64(h.ObjcClassHashTableOffset),
+		uint64(h.ObjcProtocolHashTableOffset),
+		h.ObjcProtocolClassCacheOffset,
+		uint64(h.SwiftTypeConformanceTableOffset),
+		uint64(h.SwiftMetadataConformanceTableOffset),
+		uint64(h.SwiftForeignTypeConformanceTableOffset),
+	} {
+		if o > uint64(off) && o <= math.MaxUint32 && (next == 0 || uint32(o) < next) {
+			next = uint32(o)
+		}
+	}
+	return next
+}
+
+// trimToLayout zeroes the header fields that are NOT part of the set's header layout
+// (otherwise the start of a legacy set's loaders array is misread as Swift table offsets)
+func (h *PrebuiltLoaderSetHeader) trimToLayout() {
+	if h.HeaderSize() == legacyLoaderSetHeaderSize {
+		h.SwiftTypeConformanceTableOffset = 0
+		h.SwiftMetadataConformanceTableOffset = 0
+		h.SwiftForeignTypeConformanceTableOffset = 0
+	}
+}
+
+// ParseStats are the counts and (monotonic) durations of each phase of parsing a PrebuiltLoaderSet
+type ParseStats struct {
+	TrieWalk         time.Duration // finding the set in the ProgramTrie
+	SetParse         time.Duration // parsing the whole set (includes LoaderParse)
+	LoaderParse      time.Duration // parsing the loaders (includes resolving their selector names)
+	SymbolResolution time.Duration // resolving objc selector and swift type names from the cache (see File.ResolveClosureNames)
+	Total            time.Duration
+	Loaders          int
+	JITLoaders       int
+	UnknownLoaders   int // loaders with an unknown magic (see File.TolerateUnknownLoaders)
+	SelectorFixups   int
+}
+
+func (s ParseStats) String() string {
+	return fmt.Sprintf("trie_walk: %s, set_parse: %s, loader_parse: %s (%d loaders, %d jit, %d unknown), symbol_resolution: %s (%d selector fixups), total: %s",
+		s.TrieWalk, s.SetParse, s.LoaderParse, s.Loaders, s.JITLoaders, s.UnknownLoaders, s.SymbolResolution, s.SelectorFixups, s.Total)
+}
+
+// PrebuiltLoaderSet is an mmap()ed read-only data structure which holds a set of PrebuiltLoader objects;
+// The contained PrebuiltLoader objects can be found be index O(1) or path O(n).
 type PrebuiltLoaderSet struct {
-	prebuiltLoaderSetHeader
+	PrebuiltLoaderSetHeader
 	Loaders                       []PrebuiltLoader
 	Patches                       []CachePatch
 	DyldCacheUUID                 types.UUID
@@ -642,25 +1355,616 @@ type PrebuiltLoaderSet struct {
 	SwiftTypeProtocolTable        SwiftTypeConformanceEntries
 	SwiftMetadataProtocolTable    SwiftMetadataConformanceEntries
 	SwiftForeignTypeProtocolTable SwiftForeignTypeConformanceEntries
+	SwiftTypeNames                map[BindTargetRef]string // type names resolved from the swift tables' type/metadata/foreign descriptors (unresolved descriptors are missing; see ResolveNames)
+}
+
+// CacheOptStats are the summed sizes of the prebuilt ObjC hash tables and Swift conformance tables of a set of closures
+type CacheOptStats struct {
+	Closures                     int // number of closures counted
+	OptimizedClosures            int // closures with prebuilt ObjC or Swift tables
+	Selectors                    int // selector hash table slots
+	Classes                      int // class hash table slots
+	Protocols                    int // protocol hash table slots
+	SwiftTypeConformances        int
+	SwiftMetadataConformances    int
+	SwiftForeignTypeConformances int
+}
+
+// Add adds the table sizes of pls to the stats
+func (s *CacheOptStats) Add(pls *PrebuiltLoaderSet) {
+	s.Closures++
+	if pls.HasOptimizedObjC() || pls.HasOptimizedSwift() {
+		s.OptimizedClosures++
+	}
+	if pls.SelectorTable != nil {
+		s.Selectors += len(pls.SelectorTable.Offsets)
+	}
+	if pls.ClassTable != nil {
+		s.Classes += len(pls.ClassTable.Offsets)
+	}
+	if pls.ProtocolTable != nil {
+		s.Protocols += len(pls.ProtocolTable.Offsets)
+	}
+	s.SwiftTypeConformances += len(pls.SwiftTypeProtocolTable)
+	s.SwiftMetadataConformances += len(pls.SwiftMetadataProtocolTable)
+	s.SwiftForeignTypeConformances += len(pls.SwiftForeignTypeProtocolTable)
+}
+
+// Total returns the number of optimized ObjC and Swift entries (a single number to compare across releases)
+func (s CacheOptStats) Total() int {
+	return s.Selectors + s.Classes + s.Protocols + s.SwiftTypeConformances + s.SwiftMetadataConformances + s.SwiftForeignTypeConformances
+}
+
+func (s CacheOptStats) String() string {
+	return fmt.Sprintf("closures: %d (optimized: %d), objc: (selectors: %d, classes: %d, protocols: %d), swift: (type: %d, metadata: %d, foreign-type: %d), total: %d",
+		s.Closures,
+		s.OptimizedClosures,
+		s.Selectors,
+		s.Classes,
+		s.Protocols,
+		s.SwiftTypeConformances,
+		s.SwiftMetadataConformances,
+		s.SwiftForeignTypeConformances,
+		s.Total())
+}
+
+func (h PrebuiltLoaderSetHeader) LoaderCount() int {
+	return int(h.LoadersArrayCount)
+}
+func (h PrebuiltLoaderSetHeader) CachePatchesCount() int {
+	return int(h.CachePatchCount)
+}
+func (h PrebuiltLoaderSetHeader) MustBeMissingCount() int {
+	return int(h.MustBeMissingPathsCount)
+}
+func (h PrebuiltLoaderSetHeader) HasOptimizedObjC() bool {
+	return (h.ObjcSelectorHashTableOffset != 0) || (h.ObjcClassHashTableOffset != 0) || (h.ObjcProtocolHashTableOffset != 0)
+}
+func (h PrebuiltLoaderSetHeader) HasOptimizedSwift() bool {
+	return (h.SwiftForeignTypeConformanceTableOffset != 0) || (h.SwiftMetadataConformanceTableOffset != 0) || (h.SwiftTypeConformanceTableOffset != 0)
+}
+
+// WXFinding is a loader region that is mapped both writable and executable
+type WXFinding struct {
+	Path        string
+	RegionIndex int
+	Region      Region
+	IsZeroFill  bool // zero-fill regions have no file backing and are less interesting
+}
+
+func (w WXFinding) String() string {
+	var zf string
+	if w.IsZeroFill {
+		zf = " (zero-fill)"
+	}
+	return fmt.Sprintf("%s: region[%d] %s%s", w.Path, w.RegionIndex, w.Region, zf)
+}
+
+// FindWXRegions returns every loader region that is both writable and executable (W^X violations)
+func (pls *PrebuiltLoaderSet) FindWXRegions() []WXFinding {
+	var findings []WXFinding
+	for _, pl := range pls.Loaders {
+		for idx, rg := range pl.Regions {
+			if rg.Perms().Write() && rg.Perms().Execute() {
+				findings = append(findings, WXFinding{
+					Path:        pl.Path,
+					RegionIndex: idx,
+					Region:      rg,
+					IsZeroFill:  rg.IsZeroFill(),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// loaderRefName returns the path of the loader ref points at
+// (app refs are loaders of the set itself, all others are cache images)
+func (pls *PrebuiltLoaderSet) loaderRefName(f *File, ref LoaderRef) (string, bool) {
+	switch {
+	case ref.IsMissingWeakImage():
+		return "", false
+	case ref.IsApp():
+		if int(ref.Index()) < len(pls.Loaders) {
+			return pls.Loaders[ref.Index()].Path, true
+		}
+	case int(ref.Index()) < len(f.Images):
+		return f.Images[ref.Index()].Name, true
+	}
+	return "", false
+}
+
+// ForEachCachePatch calls handler with each of the set's cache patches resolved to names/offsets
+// NOTE: patches with out-of-range indices are passed to the handler with Invalid set
+func (pls *PrebuiltLoaderSet) ForEachCachePatch(f *File, handler func(ResolvedCachePatch) error) error {
+	for _, patch := range pls.Patches {
+		rp := ResolvedCachePatch{
+			CachePatch:    patch,
+			Dylib:         fmt.Sprintf("(index=%d)", patch.DylibIndex),
+			ReplaceLoader: patch.PatchTo.LoaderRef().String(),
+			ReplaceOffset: patch.PatchTo.Offset(),
+		}
+		if patch.DylibIndex < uint32(len(f.Images)) {
+			rp.Dylib = f.Images[patch.DylibIndex].Name
+		} else {
+			rp.Invalid = true
+		}
+		if ref := patch.PatchTo.LoaderRef(); ref.IsApp() {
+			if int(ref.Index()) < len(pls.Loaders) {
+				rp.ReplaceLoader = pls.Loaders[ref.Index()].Path
+			} else {
+				rp.Invalid = true
+			}
+		} else if int(ref.Index()) < len(f.Images) {
+			rp.ReplaceLoader = f.Images[ref.Index()].Name
+		} else if !ref.IsMissingWeakImage() {
+			rp.Invalid = true
+		}
+		if err := handler(rp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoaderForVMOffset returns the loader and region whose VM extent contains the given runtime VM offset
+// NOTE: region VM offsets are relative to each loader's load address, so the first matching loader is returned
+func (pls *PrebuiltLoaderSet) LoaderForVMOffset(off uint64) (*PrebuiltLoader, *Region, bool) {
+	for lidx := range pls.Loaders {
+		pl := &pls.Loaders[lidx]
+		for ridx := range pl.Regions {
+			if off >= pl.Regions[ridx].VMOffset() && off < pl.RegionVMEnd(ridx) {
+				return pl, &pl.Regions[ridx], true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// Overrides returns the sorted names of the cache dylibs this set overrides (patches or roots)
+func (pls *PrebuiltLoaderSet) Overrides(f *File) []string {
+	var overrides []string
+	for _, patch := range pls.Patches {
+		if patch.DylibIndex < uint32(len(f.Images)) && !slices.Contains(overrides, f.Images[patch.DylibIndex].Name) {
+			overrides = append(overrides, f.Images[patch.DylibIndex].Name)
+		}
+	}
+	for _, pl := range pls.Loaders {
+		if pl.DylibInDyldCache() || slices.Contains(overrides, pl.Path) {
+			continue
+		}
+		if idx, err := f.HasImagePath(pl.Path); err == nil && idx >= 0 { // an on-disk root of a cache dylib
+			overrides = append(overrides, pl.Path)
+		}
+	}
+	slices.Sort(overrides)
+	return overrides
+}
+
+// MatchesCache returns true if the set was built against the open cache, i.e. its recorded DyldCacheUUID is the UUID
+// of f (the main cache's UUID for split caches). Cache image indices in a set that does NOT match can NOT be trusted.
+// NOTE: a set that does not record a cache UUID never matches
+func (pls *PrebuiltLoaderSet) MatchesCache(f *File) bool {
+	return !pls.DyldCacheUUID.IsNull() && pls.DyldCacheUUID == f.UUID
+}
+
+// IsExtension returns true if the set is the launch closure of an app extension (i.e. its main executable lives in an .appex bundle).
+// NOTE: neither the Loader nor the PrebuiltLoader flags record the bundle type, so the main executable's path is the only signal
+func (pls *PrebuiltLoaderSet) IsExtension() bool {
+	main, ok := pls.MainExecutable()
+	if !ok {
+		return false
+	}
+	return strings.Contains(main.Path, ".appex/")
+}
+
+// MainExecutable returns the set's main executable loader; the loader whose Origin is OriginMainExecutable
+// NOTE: this is NOT the app loader no other loader depends on as embedded frameworks can have upward links to it
+func (pls *PrebuiltLoaderSet) MainExecutable() (*PrebuiltLoader, bool) {
+	for idx := range pls.Loaders {
+		if pls.Loaders[idx].Origin() == OriginMainExecutable {
+			return &pls.Loaders[idx], true
+		}
+	}
+	return nil, false
+}
+
+// ReachableLoaders returns the indices (into Loaders) of the loaders reachable from the main executable through
+// any kind of dependent. Loaders NOT in the map are present in the set but never loaded (usually a closure
+// builder bug). Returns nil if the set has no main executable (e.g. the cache dylibs set).
+func (pls *PrebuiltLoaderSet) ReachableLoaders() map[int]bool {
+	main, ok := pls.MainExecutable()
+	if !ok {
+		return nil
+	}
+	var root int
+	for idx := range pls.Loaders {
+		if &pls.Loaders[idx] == main {
+			root = idx
+		}
+	}
+	reachable := map[int]bool{root: true}
+	queue := []int{root}
+	for len(queue) > 0 {
+		idx := queue[0]
+		queue = queue[1:]
+		for _, dep := range pls.Loaders[idx].DependentRefs {
+			if !dep.IsApp() || dep.IsMissingWeakImage() { // cache dylibs are NOT part of the set
+				continue
+			}
+			if next := int(dep.Index()); next < len(pls.Loaders) && !reachable[next] {
+				reachable[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reachable
+}
+
+// LoaderBindStat is the fixup cost of a loader (see BindDensity)
+type LoaderBindStat struct {
+	Index         int // the loader's index in the set
+	Path          string
+	BindCount     int
+	OverrideCount int
+	BindsPerKB    float64 // bind targets per KB of the loader's VM size (0 if the VM size is unknown)
+}
+
+func (s LoaderBindStat) String() string {
+	return fmt.Sprintf("%s: binds=%d, overrides=%d, binds/KB=%.2f", s.Path, s.BindCount, s.OverrideCount, s.BindsPerKB)
+}
+
+// bindsPerKB returns the loader's bind targets per KB of VM size
+func (pl *PrebuiltLoader) bindsPerKB() float64 {
+	if pl.VmSize == 0 {
+		return 0
+	}
+	return float64(len(pl.BindTargets)) / (float64(pl.VmSize) / 1024)
+}
+
+// BindDensity returns the bind target density of every loader in the set sorted by BindsPerKB (heaviest first),
+// which surfaces the loaders with the highest fixup cost at launch
+func (pls *PrebuiltLoaderSet) BindDensity() []LoaderBindStat {
+	stats := make([]LoaderBindStat, 0, len(pls.Loaders))
+	for idx := range pls.Loaders {
+		pl := &pls.Loaders[idx]
+		stats = append(stats, LoaderBindStat{
+			Index:         idx,
+			Path:          pl.Path,
+			BindCount:     len(pl.BindTargets),
+			OverrideCount: len(pl.OverrideBindTargets),
+			BindsPerKB:    pl.bindsPerKB(),
+		})
+	}
+	slices.SortStableFunc(stats, func(a, b LoaderBindStat) int {
+		return cmp.Compare(b.BindsPerKB, a.BindsPerKB)
+	})
+	return stats
+}
+
+// DependentKindCounts returns the number of dependents of each kind of the loader
+func (pl *PrebuiltLoader) DependentKindCounts() map[DependentKind]int {
+	counts := make(map[DependentKind]int)
+	for _, dep := range pl.Dependents {
+		counts[dep.Kind]++
+	}
+	return counts
+}
+
+// DependentKindCounts returns the number of dependents of each kind across all of the set's loaders
+// (many upward/reexport dependents reveal umbrella framework structures)
+func (pls *PrebuiltLoaderSet) DependentKindCounts() map[DependentKind]int {
+	counts := make(map[DependentKind]int)
+	for idx := range pls.Loaders {
+		for kind, n := range pls.Loaders[idx].DependentKindCounts() {
+			counts[kind] += n
+		}
+	}
+	return counts
+}
+
+// DependentsOf returns the loaders that have the named dylib as a dependent (matching the dylib's
+// path as well as its install-name/AltPath if the dylib is one of the set's loaders)
+func (pls *PrebuiltLoaderSet) DependentsOf(name string) []*PrebuiltLoader {
+	names := map[string]bool{name: true}
+	appLoaders := make(map[uint16]*PrebuiltLoader)
+	for idx := range pls.Loaders {
+		pl := &pls.Loaders[idx]
+		if pl.Ref.IsApp() {
+			appLoaders[pl.Ref.Index()] = pl
+		}
+		if pl.Path == name || (len(pl.AltPath) > 0 && pl.AltPath == name) {
+			names[pl.Path] = true
+			if len(pl.AltPath) > 0 {
+				names[pl.AltPath] = true
+			}
+		}
+	}
+
+	var dependents []*PrebuiltLoader
+	for idx := range pls.Loaders {
+		pl := &pls.Loaders[idx]
+		for didx, dep := range pl.Dependents {
+			match := names[dep.Name]
+			if !match && didx < len(pl.DependentRefs) && pl.DependentRefs[didx].IsApp() {
+				if target, ok := appLoaders[pl.DependentRefs[didx].Index()]; ok {
+					match = names[target.Path] || (len(target.AltPath) > 0 && names[target.AltPath])
+				}
+			}
+			if match {
+				dependents = append(dependents, pl)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// go-macho does NOT export its platform constants
+const (
+	platformMacOS       = types.Platform(1) // PLATFORM_MACOS
+	platformMacCatalyst = types.Platform(6) // PLATFORM_MACCATALYST
+)
+
+// Platform returns the platform the closure targets; the cache's platform, refined to Mac Catalyst on macOS when
+// a loader is the Catalyst side of an unzippered twin or a Catalyst capable loader links against /System/iOSSupport
+// NOTE: SupportsCatalyst() alone is NOT enough as zippered binaries support both macOS and Catalyst
+func (pls *PrebuiltLoaderSet) Platform(f *File) string {
+	if platform := f.Headers[f.UUID].Platform; platform != platformMacOS {
+		return platform.String()
+	}
+	for _, pl := range pls.Loaders {
+		if pl.IsCatalystOverride() {
+			return platformMacCatalyst.String()
+		}
+		if !pl.SupportsCatalyst() {
+			continue
+		}
+		for _, dep := range pl.Dependents {
+			if strings.HasPrefix(dep.Name, "/System/iOSSupport/") {
+				return platformMacCatalyst.String()
+			}
+		}
+	}
+	return platformMacOS.String()
+}
+
+// PathAliases returns a map of install-name (AltPath) to real path (Path) for every loader that has both
+func (pls *PrebuiltLoaderSet) PathAliases() map[string]string {
+	aliases := make(map[string]string)
+	for _, pl := range pls.Loaders {
+		if len(pl.AltPath) > 0 && len(pl.Path) > 0 {
+			aliases[pl.AltPath] = pl.Path
+		}
+	}
+	return aliases
+}
+
+// LoaderOrigin is where a loader's binary lives
+type LoaderOrigin uint8
+
+const (
+	OriginCacheDylib     LoaderOrigin = iota // a dylib in the dyld_shared_cache
+	OriginAppEmbedded                        // a dylib on disk (e.g. an app's embedded framework)
+	OriginMainExecutable                     // the closure's main executable
+)
+
+func (o LoaderOrigin) String() string {
+	switch o {
+	case OriginCacheDylib:
+		return "cache dylib"
+	case OriginAppEmbedded:
+		return "app embedded"
+	case OriginMainExecutable:
+		return "main executable"
+	default:
+		return fmt.Sprintf("unknown %d", o)
+	}
+}
+
+// Origin returns where the loader's binary lives
+// NOTE: dyld always builds a launch closure with the main executable as its first (app) loader
+func (pl *PrebuiltLoader) Origin() LoaderOrigin {
+	switch {
+	case pl.DylibInDyldCache() || !pl.Ref.IsApp():
+		return OriginCacheDylib
+	case pl.Ref.Index() == 0:
+		return OriginMainExecutable
+	default:
+		return OriginAppEmbedded
+	}
+}
+
+// IsLaunchEssential returns true if dyld will never unload the loader (it is part of launch or
+// has non-unloadable data such as objc or TLVs).
+// NOTE: this is the per-loader half of the heuristic, EssentialLoaders also requires the loader to be in the launch root set
+func (pl *PrebuiltLoader) IsLaunchEssential() bool {
+	return pl.NeverUnload()
 }
 
-func (pls PrebuiltLoaderSet) HasOptimizedObjC() bool {
-	return (pls.ObjcSelectorHashTableOffset != 0) || (pls.ObjcClassHashTableOffset != 0) || (pls.ObjcProtocolHashTableOffset != 0)
+// EssentialLoaders returns the loaders that are loaded into EVERY launch of the set's executable:
+// loaders that are IsLaunchEssential AND are in the root set, meaning the main executable itself or a loader
+// reachable from it through regular or re-export dependents only (weak-linked dependents may be missing and
+// upward dependents are not required to load first). If the set has no main executable (e.g. the cache dylibs
+// set) every loader is considered to be in the root set.
+func (pls *PrebuiltLoaderSet) EssentialLoaders() []*PrebuiltLoader {
+	var essential []*PrebuiltLoader
+
+	main, ok := pls.MainExecutable()
+	if !ok {
+		for idx := range pls.Loaders {
+			if pls.Loaders[idx].IsLaunchEssential() {
+				essential = append(essential, &pls.Loaders[idx])
+			}
+		}
+		return essential
+	}
+
+	byIndex := make(map[uint16]*PrebuiltLoader)
+	for idx := range pls.Loaders {
+		if pls.Loaders[idx].Ref.IsApp() {
+			byIndex[pls.Loaders[idx].Ref.Index()] = &pls.Loaders[idx]
+		}
+	}
+
+	inRoot := map[*PrebuiltLoader]bool{main: true}
+	queue := []*PrebuiltLoader{main}
+	for len(queue) > 0 {
+		pl := queue[0]
+		queue = queue[1:]
+		for idx, dep := range pl.DependentRefs {
+			if !dep.IsApp() { // cache dylibs are NOT part of the set
+				continue
+			}
+			if idx < len(pl.Dependents) && pl.Dependents[idx].Kind != KindNormal && pl.Dependents[idx].Kind != KindReexport {
+				continue
+			}
+			if next, ok := byIndex[dep.Index()]; ok && !inRoot[next] {
+				inRoot[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	for idx := range pls.Loaders {
+		if inRoot[&pls.Loaders[idx]] && pls.Loaders[idx].IsLaunchEssential() {
+			essential = append(essential, &pls.Loaders[idx])
+		}
+	}
+	return essential
 }
-func (pls PrebuiltLoaderSet) HasOptimizedSwift() bool {
-	return (pls.SwiftForeignTypeConformanceTableOffset != 0) || (pls.SwiftMetadataConformanceTableOffset != 0) || (pls.SwiftTypeConformanceTableOffset != 0)
+
+// LoadersWithROData returns the loaders that have the has-ro-data flag set (their __DATA_CONST is made read-only
+// after fixups); use ReadOnlyDataRegions to check the corresponding regions are actually marked read-only data
+func (pls *PrebuiltLoaderSet) LoadersWithROData() []*PrebuiltLoader {
+	var ro []*PrebuiltLoader
+	for idx := range pls.Loaders {
+		if pls.Loaders[idx].HasReadOnlyData() {
+			ro = append(ro, &pls.Loaders[idx])
+		}
+	}
+	return ro
+}
+
+// ContentHash returns a SHA-256 over the set's canonical serialized form (the raw on-disk fields, not
+// the names resolved from the cache) so that exec paths with identical closures can be grouped together.
+// The hash is stable across runs.
+func (pls *PrebuiltLoaderSet) ContentHash() [32]byte {
+	h := sha256.New()
+	// NOTE: writes to a hash.Hash never fail and all the values below are fixed-size
+	w := func(data any) { binary.Write(h, binary.LittleEndian, data) }
+	ws := func(s string) {
+		w(uint32(len(s)))
+		h.Write([]byte(s))
+	}
+	w(pls.PrebuiltLoaderSetHeader)
+	for _, pl := range pls.Loaders {
+		w(pl.prebuiltLoaderHeader)
+		ws(pl.Path)
+		ws(pl.AltPath)
+		w(pl.DependentRefs)
+		for _, dep := range pl.Dependents {
+			w(dep.Kind)
+		}
+		if pl.FileValidation != nil {
+			w(*pl.FileValidation)
+		}
+		w(pl.Regions)
+		w(pl.BindTargets)
+		w(pl.DylibPatches)
+		w(pl.OverrideBindTargets)
+		if pl.ObjcFixupInfo != nil {
+			w(*pl.ObjcFixupInfo)
+		}
+		w(pl.ObjcCanonicalProtocolFixups)
+		w(pl.ObjcSelectorFixups)
+	}
+	w(pls.Patches)
+	w(pls.DyldCacheUUID)
+	for _, path := range pls.MustBeMissingPaths {
+		ws(path)
+	}
+	if pls.SelectorTable != nil {
+		w(pls.SelectorTable.objCStringTable)
+		w(pls.SelectorTable.Tab)
+		w(pls.SelectorTable.Checkbytes)
+		w(pls.SelectorTable.Offsets)
+	}
+	for _, o := range []*ObjCClassOpt{pls.ClassTable, pls.ProtocolTable} {
+		if o != nil {
+			w(o.objCStringTable)
+			w(o.Tab)
+			w(o.Checkbytes)
+			w(o.Offsets)
+			w(o.Classes)
+			w(o.Duplicates)
+		}
+	}
+	w(pls.SwiftTypeProtocolTable)
+	w(pls.SwiftMetadataProtocolTable)
+	w(pls.SwiftForeignTypeProtocolTable)
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// PartitionByObjC splits the set's loaders into those that have ObjC and those that do not (C/Swift only)
+func (pls *PrebuiltLoaderSet) PartitionByObjC() (withObjC, without []*PrebuiltLoader) {
+	for idx := range pls.Loaders {
+		if pls.Loaders[idx].HasObjC() {
+			withObjC = append(withObjC, &pls.Loaders[idx])
+		} else {
+			without = append(without, &pls.Loaders[idx])
+		}
+	}
+	return withObjC, without
+}
+
+// Summary returns a one line summary of the set's counts
+func (pls *PrebuiltLoaderSet) Summary() string {
+	withObjC, without := pls.PartitionByObjC()
+	kinds := pls.DependentKindCounts()
+	return fmt.Sprintf("loaders: %d (objc: %d, no-objc: %d), dependents: (regular: %d, weak: %d, reexport: %d, upward: %d), cache-patches: %d, must-be-missing: %d, optimized-objc: %t, optimized-swift: %t",
+		len(pls.Loaders),
+		len(withObjC),
+		len(without),
+		kinds[KindNormal],
+		kinds[KindWeakLink],
+		kinds[KindReexport],
+		kinds[KindUpward],
+		len(pls.Patches),
+		len(pls.MustBeMissingPaths),
+		pls.HasOptimizedObjC(),
+		pls.HasOptimizedSwift())
 }
+
 func (pls PrebuiltLoaderSet) String(f *File) string {
+	return pls.StringFiltered(f, func(*PrebuiltLoader) bool { return true })
+}
+
+// StringFiltered is String but ONLY prints the loaders for which include returns true
+// (the set's header and objc/swift tables are always printed)
+func (pls *PrebuiltLoaderSet) StringFiltered(f *File, include func(*PrebuiltLoader) bool) string {
 	var out string
 	out += "PrebuiltLoaderSet:\n"
 	out += fmt.Sprintf("  Version: %x\n", pls.VersionHash)
 	if !pls.DyldCacheUUID.IsNull() {
 		out += fmt.Sprintf("  DyldCacheUUID: %s\n", pls.DyldCacheUUID)
 	}
-	if len(pls.Loaders) > 0 {
-		out += "\nLoaders:\n"
-		for _, pl := range pls.Loaders {
-			if len(pls.Loaders) > 1 {
+	var included []*PrebuiltLoader
+	for idx := range pls.Loaders {
+		if include(&pls.Loaders[idx]) {
+			included = append(included, &pls.Loaders[idx])
+		}
+	}
+	if len(included) > 0 {
+		if len(included) < len(pls.Loaders) {
+			out += fmt.Sprintf("\nLoaders: (%d of %d)\n", len(included), len(pls.Loaders))
+		} else {
+			out += "\nLoaders:\n"
+		}
+		for _, pl := range included {
+			if len(included) > 1 {
 				out += "---\n"
 			}
 			out += pl.String(f)
@@ -668,10 +1972,14 @@ func (pls PrebuiltLoaderSet) String(f *File) string {
 	}
 	if pls.SelectorTable != nil {
 		out += "\nObjC Selector Table:\n"
-		for _, bt := range pls.SelectorTable.Offsets {
+		for idx, bt := range pls.SelectorTable.Offsets {
 			if bt.IsAbsolute() {
 				continue
 			}
+			if idx < len(pls.SelectorTable.Names) && pls.SelectorTable.Names[idx] != "" {
+				out += fmt.Sprintf("  selector %q -> %s\n", pls.SelectorTable.Names[idx], bt.String(f))
+				continue
+			}
 			out += fmt.Sprintf("  %s\n", bt.String(f))
 		}
 	}
@@ -739,24 +2047,16 @@ func (pls PrebuiltLoaderSet) String(f *File) string {
 	}
 	if len(pls.Patches) > 0 {
 		out += "\nCache Overrides:\n"
-		for _, patch := range pls.Patches {
+		pls.ForEachCachePatch(f, func(patch ResolvedCachePatch) error {
 			if len(pls.Patches) > 1 {
 				out += "---\n"
 			}
-			img := fmt.Sprintf("(index=%d)", patch.DylibIndex)
-			if patch.DylibIndex < uint32(len(f.Images)) {
-				img = f.Images[patch.DylibIndex].Name
-			}
-			out += fmt.Sprintf("  cache-dylib:    %s\n", img)
+			out += fmt.Sprintf("  cache-dylib:    %s\n", patch.Dylib)
 			out += fmt.Sprintf("  dylib-offset:   %#08x\n", patch.DylibVMOffset)
-			if patch.PatchTo.LoaderRef().Index() < uint16(len(f.Images)) {
-				img = f.Images[patch.PatchTo.LoaderRef().Index()].Name
-			} else {
-				img = patch.PatchTo.LoaderRef().String()
-			}
-			out += fmt.Sprintf("  replace-loader: %s\n", img)
-			out += fmt.Sprintf("  replace-offset: %#08x\n", patch.PatchTo.Offset())
-		}
+			out += fmt.Sprintf("  replace-loader: %s\n", patch.ReplaceLoader)
+			out += fmt.Sprintf("  replace-offset: %#08x\n", patch.ReplaceOffset)
+			return nil
+		})
 	}
 
 	return out
@@ -777,11 +2077,40 @@ type objCStringTable struct {
 	// BindTargetRef offsets[capacity]; /* offsets from &capacity to cstrings */
 }
 
+// AllSelectors returns the sorted (deduplicated) ObjC selectors the closure optimizes, from both the
+// set's selector hash table and the loaders' selector fixups.
+// NOTE: this only covers the selectors the closure optimizes (NOT every selref in the loaders) and
+// only the selectors that were resolved from the cache (see ResolveNames and File.ResolveClosureNames)
+func (pls *PrebuiltLoaderSet) AllSelectors() []string {
+	seen := make(map[string]bool)
+	if pls.SelectorTable != nil {
+		for _, name := range pls.SelectorTable.Names {
+			if len(name) > 0 {
+				seen[name] = true
+			}
+		}
+	}
+	for _, pl := range pls.Loaders {
+		for _, name := range pl.ObjcSelectorFixupNames {
+			if len(name) > 0 {
+				seen[name] = true
+			}
+		}
+	}
+	sels := make([]string, 0, len(seen))
+	for name := range seen {
+		sels = append(sels, name)
+	}
+	slices.Sort(sels)
+	return sels
+}
+
 type ObjCSelectorOpt struct {
 	objCStringTable
 	Tab        []byte          /* tab[mask+1] (always power-of-2). Rounded up to roundedTabSize */
 	Checkbytes []byte          /* check byte for each string. Rounded up to roundedCheckBytesSize */
 	Offsets    []BindTargetRef /* offsets from &capacity to cstrings */
+	Names      []string        // selector strings resolved from Offsets ("" if empty or unresolved, nil if NOT resolved; see PrebuiltLoaderSet.ResolveNames)
 }
 
 type ObjCClassOpt struct {
@@ -807,7 +2136,6 @@ type SwiftConformanceMultiMap struct {
 type SwiftTypeConformanceEntries []SwiftTypeProtocolNodeEntryT
 
 func (ents SwiftTypeConformanceEntries) ForEachEntry(handler func(SwiftTypeProtocolConformanceDiskLocationKey, []SwiftTypeProtocolConformanceDiskLocation)) {
-	var vals []SwiftTypeProtocolConformanceDiskLocation
 	for _, head := range ents {
 		nextNode := head.Next
 		if !nextNode.HasAnyDuplicates() {
@@ -816,7 +2144,7 @@ func (ents SwiftTypeConformanceEntries) ForEachEntry(handler func(SwiftTypeProto
 		if !nextNode.IsDuplicateHead() {
 			continue
 		}
-		vals = append(vals, head.Value) // add head node
+		vals := []SwiftTypeProtocolConformanceDiskLocation{head.Value} // add head node
 		for ents[nextNode.NextIndex()].Next.HasMoreDuplicates() {
 			vals = append(vals, ents[nextNode.NextIndex()].Value)
 			nextNode = ents[nextNode.NextIndex()].Next
@@ -845,7 +2173,6 @@ type SwiftTypeProtocolConformanceDiskLocation struct {
 type SwiftMetadataConformanceEntries []SwiftMetadataConformanceNodeEntryT
 
 func (ents SwiftMetadataConformanceEntries) ForEachEntry(handler func(SwiftMetadataProtocolConformanceDiskLocationKey, []SwiftMetadataProtocolConformanceDiskLocation)) {
-	var vals []SwiftMetadataProtocolConformanceDiskLocation
 	for _, head := range ents {
 		nextNode := head.Next
 		if !nextNode.HasAnyDuplicates() {
@@ -854,7 +2181,7 @@ func (ents SwiftMetadataConformanceEntries) ForEachEntry(handler func(SwiftMetad
 		if !nextNode.IsDuplicateHead() {
 			continue
 		}
-		vals = append(vals, head.Value) // add head node
+		vals := []SwiftMetadataProtocolConformanceDiskLocation{head.Value} // add head node
 		for ents[nextNode.NextIndex()].Next.HasMoreDuplicates() {
 			vals = append(vals, ents[nextNode.NextIndex()].Value)
 			nextNode = ents[nextNode.NextIndex()].Next
@@ -883,7 +2210,6 @@ type SwiftMetadataProtocolConformanceDiskLocation struct {
 type SwiftForeignTypeConformanceEntries []SwiftForeignTypeConformanceNodeEntryT
 
 func (ents SwiftForeignTypeConformanceEntries) ForEachEntry(handler func(SwiftForeignTypeProtocolConformanceDiskLocationKey, []SwiftForeignTypeProtocolConformanceDiskLocation)) {
-	var vals []SwiftForeignTypeProtocolConformanceDiskLocation
 	for _, head := range ents {
 		nextNode := head.Next
 		if !nextNode.HasAnyDuplicates() {
@@ -892,7 +2218,7 @@ func (ents SwiftForeignTypeConformanceEntries) ForEachEntry(handler func(SwiftFo
 		if !nextNode.IsDuplicateHead() {
 			continue
 		}
-		vals = append(vals, head.Value) // add head node
+		vals := []SwiftForeignTypeProtocolConformanceDiskLocation{head.Value} // add head node
 		for ents[nextNode.NextIndex()].Next.HasMoreDuplicates() {
 			vals = append(vals, ents[nextNode.NextIndex()].Value)
 			nextNode = ents[nextNode.NextIndex()].Next
@@ -920,6 +2246,70 @@ type SwiftForeignTypeProtocolConformanceDiskLocation struct {
 	ProtocolConformance BindTargetRef
 }
 
+// SwiftConformanceKind is the conformance table a SwiftConformance came from
+type SwiftConformanceKind uint8
+
+const (
+	SwiftConformanceType        SwiftConformanceKind = 0 // keyed by a type descriptor
+	SwiftConformanceMetadata    SwiftConformanceKind = 1 // keyed by a metadata (e.g. an objc class)
+	SwiftConformanceForeignType SwiftConformanceKind = 2 // keyed by a foreign type's name
+)
+
+func (k SwiftConformanceKind) String() string {
+	switch k {
+	case SwiftConformanceType:
+		return "type"
+	case SwiftConformanceMetadata:
+		return "metadata"
+	case SwiftConformanceForeignType:
+		return "foreign"
+	default:
+		return fmt.Sprintf("unknown %d", k)
+	}
+}
+
+// SwiftConformance is an optimized protocol conformance from one of a PrebuiltLoaderSet's swift conformance tables
+type SwiftConformance struct {
+	Kind        SwiftConformanceKind
+	TypeName    string
+	Type        BindTargetRef // the type descriptor, metadata or foreign descriptor (see Kind)
+	Protocol    BindTargetRef
+	Conformance BindTargetRef
+}
+
+func (c SwiftConformance) String(f *File) string {
+	return fmt.Sprintf("%s (%s): %s protocol, %s conformance", c.TypeName, c.Kind, c.Protocol.String(f), c.Conformance.String(f))
+}
+
+// ConformancesForType returns the optimized protocol conformances of the swift type typeName from all three of the
+// set's conformance tables.
+// NOTE: types are matched by the names resolved from the cache (see ResolveNames and File.ResolveClosureNames);
+// types in app loaders can NOT be resolved and metadata entries are matched by their objc class name
+func (pls *PrebuiltLoaderSet) ConformancesForType(typeName string) []SwiftConformance {
+	var confs []SwiftConformance
+	add := func(kind SwiftConformanceKind, typ, proto BindTargetRef, conformance BindTargetRef) {
+		if name, ok := pls.SwiftTypeNames[typ]; ok && name == typeName {
+			confs = append(confs, SwiftConformance{Kind: kind, TypeName: name, Type: typ, Protocol: proto, Conformance: conformance})
+		}
+	}
+	pls.SwiftTypeProtocolTable.ForEachEntry(func(key SwiftTypeProtocolConformanceDiskLocationKey, values []SwiftTypeProtocolConformanceDiskLocation) {
+		for _, v := range values {
+			add(SwiftConformanceType, key.TypeDescriptor, key.Protocol, v.ProtocolConformance)
+		}
+	})
+	pls.SwiftMetadataProtocolTable.ForEachEntry(func(key SwiftMetadataProtocolConformanceDiskLocationKey, values []SwiftMetadataProtocolConformanceDiskLocation) {
+		for _, v := range values {
+			add(SwiftConformanceMetadata, key.MetadataDescriptor, key.Protocol, v.ProtocolConformance)
+		}
+	})
+	pls.SwiftForeignTypeProtocolTable.ForEachEntry(func(key SwiftForeignTypeProtocolConformanceDiskLocationKey, values []SwiftForeignTypeProtocolConformanceDiskLocation) {
+		for _, v := range values {
+			add(SwiftConformanceForeignType, key.ForeignDescriptor, key.Protocol, v.ProtocolConformance)
+		}
+	})
+	return confs
+}
+
 type NextNode uint32
 
 func (nn NextNode) IsDuplicateHead() bool {
@@ -0,0 +1,367 @@
+package dyld
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blacktop/go-macho"
+	"github.com/blacktop/go-macho/types"
+)
+
+// Validate checks the PrebuiltLoaderSet for structural problems (e.g. loader refs or image indices
+// that are out of range) and returns every problem found
+func (pls *PrebuiltLoaderSet) Validate(f *File) []error {
+	return pls.validate(f, false)
+}
+
+// IsValid returns true if the PrebuiltLoaderSet has no structural problems (stops at the first problem found)
+func (pls *PrebuiltLoaderSet) IsValid(f *File) bool {
+	return len(pls.validate(f, true)) == 0
+}
+
+func (pls *PrebuiltLoaderSet) validate(f *File, failFast bool) []error {
+	var errs []error
+
+	check := func(err error) bool {
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return failFast && len(errs) > 0
+	}
+
+	for idx, pl := range pls.Loaders {
+		// a loader's self ref is its position in the loaders array (a mismatch means misaligned/corrupt loaders)
+		if int(pl.Ref.Index()) != idx {
+			if check(fmt.Errorf("loader[%d] %s: self ref %s does not match its index in the set", idx, pl.Path, pl.Ref)) {
+				return errs
+			}
+		}
+		for didx, dep := range pl.DependentRefs {
+			if check(pls.checkLoaderRef(f, dep, "loader[%d] %s: dependent[%d]", idx, pl.Path, didx)) {
+				return errs
+			}
+		}
+		for bidx, bt := range pl.BindTargets {
+			if bt.IsAbsolute() {
+				continue
+			}
+			if check(pls.checkLoaderRef(f, bt.LoaderRef(), "loader[%d] %s: bind-target[%d]", idx, pl.Path, bidx)) {
+				return errs
+			}
+		}
+		// the objc flag and the objc binary info should agree (a mismatch means a parse gap or unusual binary)
+		if pl.IsPrebuilt() && pl.HasObjC() != (pl.ObjcBinaryInfoOffset != 0) {
+			if check(fmt.Errorf("loader[%d] %s: has-objc=%t does not agree with objc binary info offset %#x", idx, pl.Path, pl.HasObjC(), pl.ObjcBinaryInfoOffset)) {
+				return errs
+			}
+		}
+		if pl.ReadOnlyDataMismatch() {
+			if check(fmt.Errorf("loader[%d] %s: has-ro-data=%t/has-ro-objc=%t does not agree with its %d read-only data region(s)",
+				idx, pl.Path, pl.HasReadOnlyData(), pl.HasReadOnlyObjC(), len(pl.ReadOnlyDataRegions()))) {
+				return errs
+			}
+		}
+		for _, pair := range pl.OverlappingRegions() {
+			if check(fmt.Errorf("loader[%d] %s: region[%d] overlaps region[%d]", idx, pl.Path, pair[0], pair[1])) {
+				return errs
+			}
+		}
+		if pl.IndexOfTwin != NoUnzipperedTwin && int(pl.IndexOfTwin) >= len(f.Images) {
+			if check(fmt.Errorf("loader[%d] %s: %w", idx, pl.Path, &OffsetRangeError{Field: "twin image index", Offset: uint64(pl.IndexOfTwin), Limit: uint64(len(f.Images))})) {
+				return errs
+			}
+		}
+	}
+
+	for _, pair := range pls.duplicatePaths() {
+		if check(fmt.Errorf("loader[%d] %s: has the same path as loader[%d]", pair[1], pls.Loaders[pair[1]].Path, pair[0])) {
+			return errs
+		}
+	}
+
+	if reachable := pls.ReachableLoaders(); reachable != nil {
+		for idx, pl := range pls.Loaders {
+			if !reachable[idx] {
+				if check(fmt.Errorf("loader[%d] %s: is NOT reachable from the main executable", idx, pl.Path)) {
+					return errs
+				}
+			}
+		}
+	}
+
+	for idx, patch := range pls.Patches {
+		if patch.DylibIndex >= uint32(len(f.Images)) {
+			if check(fmt.Errorf("cache-patch[%d]: %w", idx, &OffsetRangeError{Field: "dylib image index", Offset: uint64(patch.DylibIndex), Limit: uint64(len(f.Images))})) {
+				return errs
+			}
+		}
+		if !patch.PatchTo.IsAbsolute() {
+			if check(pls.checkLoaderRef(f, patch.PatchTo.LoaderRef(), "cache-patch[%d]: patch-to", idx)) {
+				return errs
+			}
+		}
+	}
+
+	return errs
+}
+
+// duplicatePaths returns the index pairs (first, duplicate) of loaders with the same path; a set never has two loaders
+// for the same file, so any duplicate means a closure-generation bug or misaligned loaders (loaders without a path are skipped)
+func (pls *PrebuiltLoaderSet) duplicatePaths() [][2]int {
+	var dups [][2]int
+	first := make(map[string]int)
+	for idx, pl := range pls.Loaders {
+		if len(pl.Path) == 0 {
+			continue
+		}
+		if prev, ok := first[pl.Path]; ok {
+			dups = append(dups, [2]int{prev, idx})
+			continue
+		}
+		first[pl.Path] = idx
+	}
+	return dups
+}
+
+// checkLoaderRef verifies that a LoaderRef points at a loader in this set (app) or an image in the cache
+func (pls *PrebuiltLoaderSet) checkLoaderRef(f *File, ref LoaderRef, format string, args ...any) error {
+	if ref.IsMissingWeakImage() {
+		return nil
+	}
+	if ref.IsApp() {
+		if int(ref.Index()) >= len(pls.Loaders) {
+			return fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), &OffsetRangeError{Field: "app loader index", Offset: uint64(ref.Index()), Limit: uint64(len(pls.Loaders))})
+		}
+		return nil
+	}
+	if int(ref.Index()) >= len(f.Images) {
+		return fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), &OffsetRangeError{Field: "cache image index", Offset: uint64(ref.Index()), Limit: uint64(len(f.Images))})
+	}
+	return nil
+}
+
+// ValidateAgainst checks that the binary in r (of the given size) is the one pl was built against by comparing
+// the CDHash of the slice at FileValidation.SliceOffset (r can be a fat binary)
+// NOTE: the inode/mtime validation can NOT be checked from an io.ReaderAt so only the CDHash is compared
+func (pl *PrebuiltLoader) ValidateAgainst(r io.ReaderAt, size int64) error {
+	fv := pl.FileValidation
+	if fv == nil || !fv.CheckCDHash {
+		return nil
+	}
+
+	sr, err := fv.sliceReader(r, size)
+	if err != nil {
+		return err
+	}
+	m, err := macho.NewFile(sr)
+	if err != nil {
+		return fmt.Errorf("failed to parse MachO slice at %#x of %s: %w", fv.SliceOffset, pl.Path, err)
+	}
+	cs := m.CodeSignature()
+	if cs == nil {
+		return fmt.Errorf("MachO slice at %#x of %s is NOT code signed", fv.SliceOffset, pl.Path)
+	}
+
+	// the closure stores the CDHash truncated to 20 bytes
+	want := fv.CDHashString()
+	var got []string
+	for _, cd := range cs.CodeDirectories {
+		if strings.HasPrefix(cd.CDHash, want) {
+			return nil
+		}
+		got = append(got, cd.CDHash)
+	}
+
+	return &CDHashMismatchError{
+		Path:        pl.Path,
+		SliceOffset: fv.SliceOffset,
+		Expected:    want,
+		Got:         got,
+	}
+}
+
+// sliceReader returns the slice of the (possibly fat) binary in r the fileValidation refers to
+func (fv *fileValidation) sliceReader(r io.ReaderAt, size int64) (*io.SectionReader, error) {
+	if fv.SliceOffset >= uint64(size) {
+		return nil, &OffsetRangeError{Field: "file validation slice offset", Offset: fv.SliceOffset, Limit: uint64(size)}
+	}
+	return io.NewSectionReader(r, int64(fv.SliceOffset), size-int64(fv.SliceOffset)), nil
+}
+
+// CheckVersion checks that the PrebuiltLoaderSet was built by the same dyld as the cache f
+// NOTE: the cache header does NOT store dyld's version hash, so the VersionHash of the cache's
+// dylibs PrebuiltLoaderSet (which is always built along with the cache) is used instead
+func (pls *PrebuiltLoaderSet) CheckVersion(f *File) error {
+	uuid, off, err := f.getDylibPrebuiltLoaderSetOffset()
+	if err != nil {
+		return err
+	}
+	hdr, err := f.readLoaderSetHeader(uuid, off)
+	if err != nil {
+		return fmt.Errorf("failed to read the cache dylibs PrebuiltLoaderSet header: %w", err)
+	}
+	if pls.VersionHash != hdr.VersionHash {
+		return &ErrVersionMismatch{Closure: pls.VersionHash, Cache: hdr.VersionHash}
+	}
+	return nil
+}
+
+// VerifyReport is the result of verifying a closure's loaders against the binaries in a filesystem root
+type VerifyReport struct {
+	Root       string
+	Verified   []string         // loader paths whose binary matches the closure (CDHash, else inode and mtime)
+	Missing    []string         // loader paths with NO binary under the root
+	Mismatched map[string]error // loader path to why its binary does NOT match
+	Unverified []string         // loader paths without validation info (nothing to check) or in the dyld_shared_cache
+	// InodeMtimeChanged are loader paths whose binary exists but has a different inode or mtime than the closure
+	// recorded (always the case for a copy of the filesystem, so they do NOT fail OK)
+	InodeMtimeChanged []string
+	Extra             []string // Mach-Os beside a loader's binary (in the same directory) that the closure does NOT reference
+}
+
+func (r *VerifyReport) String() string {
+	return fmt.Sprintf("%s: verified: %d, missing: %d, mismatched: %d, unverified: %d, inode/mtime changed: %d, extra: %d",
+		r.Root, len(r.Verified), len(r.Missing), len(r.Mismatched), len(r.Unverified), len(r.InodeMtimeChanged), len(r.Extra))
+}
+
+// OK returns true if every loader with validation info has a matching binary under the root
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Mismatched) == 0
+}
+
+// VerifyAgainstRoot checks every loader's binary under root (e.g. a mounted filesystem or device dump)
+// exists and has the CDHash (see ValidateAgainst) or inode and mtime the closure was built against, and lists the
+// Mach-Os in the loaders' directories the closure does NOT reference (e.g. plugins or frameworks it never loads)
+// NOTE: the closure format does NOT store the binary's UUID so only the CDHash can be compared
+func (pls *PrebuiltLoaderSet) VerifyAgainstRoot(root string) (*VerifyReport, error) {
+	if fi, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is NOT a directory", root)
+	}
+
+	report := &VerifyReport{
+		Root:       root,
+		Mismatched: make(map[string]error),
+	}
+	var dirs []string // in loader order
+	seenDirs := make(map[string]bool)
+	referenced := make(map[string]bool)
+	for idx := range pls.Loaders {
+		pl := &pls.Loaders[idx]
+		if len(pl.Path) == 0 {
+			continue
+		}
+		if pl.DylibInDyldCache() {
+			report.Unverified = append(report.Unverified, pl.Path)
+			continue
+		}
+		path := filepath.Join(root, pl.Path)
+		referenced[path] = true
+		if len(pl.AltPath) > 0 {
+			referenced[filepath.Join(root, pl.AltPath)] = true
+		}
+		fi, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			report.Missing = append(report.Missing, pl.Path)
+			continue
+		} else if err != nil {
+			report.Mismatched[pl.Path] = err
+			continue
+		}
+		if dir := filepath.Dir(path); !seenDirs[dir] {
+			seenDirs[dir] = true
+			dirs = append(dirs, dir)
+		}
+
+		fv := pl.FileValidation
+		inodeMtimeOK := fv == nil || !fv.CheckInodeMtime || fv.matchesInodeMtime(fi)
+		if !inodeMtimeOK {
+			report.InodeMtimeChanged = append(report.InodeMtimeChanged, pl.Path)
+		}
+		switch {
+		case fv != nil && fv.CheckCDHash:
+			if err := pl.verifyAgainstFile(path); err != nil {
+				report.Mismatched[pl.Path] = err
+			} else {
+				report.Verified = append(report.Verified, pl.Path)
+			}
+		case fv != nil && fv.CheckInodeMtime:
+			if inodeMtimeOK {
+				report.Verified = append(report.Verified, pl.Path)
+			}
+		default:
+			report.Unverified = append(report.Unverified, pl.Path)
+		}
+	}
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if !entry.Type().IsRegular() || referenced[path] || !isMachOFile(path) {
+				continue
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return nil, err
+			}
+			report.Extra = append(report.Extra, "/"+filepath.ToSlash(rel))
+		}
+	}
+
+	return report, nil
+}
+
+// matchesInodeMtime returns true if fi has the inode (where the platform has them) and mtime dyld recorded
+func (fv *fileValidation) matchesInodeMtime(fi fs.FileInfo) bool {
+	if inode, ok := fileInode(fi); ok && inode != fv.Inode {
+		return false
+	}
+	return uint64(fi.ModTime().Unix()) == fv.Mtime
+}
+
+// isMachOFile returns true if the file at path starts with a (thin or fat) Mach-O magic
+func isMachOFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var magic [4]byte
+	if _, err := io.ReadFull(f, magic[:]); err != nil {
+		return false
+	}
+	switch {
+	case types.Magic(binary.LittleEndian.Uint32(magic[:])) == types.Magic32,
+		types.Magic(binary.LittleEndian.Uint32(magic[:])) == types.Magic64,
+		types.Magic(binary.BigEndian.Uint32(magic[:])) == types.MagicFat:
+		return true
+	default:
+		return false
+	}
+}
+
+func (pl *PrebuiltLoader) verifyAgainstFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return pl.ValidateAgainst(f, fi.Size())
+}
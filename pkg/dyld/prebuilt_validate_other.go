@@ -0,0 +1,10 @@
+//go:build !unix
+
+package dyld
+
+import "io/fs"
+
+// fileInode returns the inode of the file fi describes (there are NO inodes on this platform)
+func fileInode(fi fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package dyld
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileInode returns the inode of the file fi describes
+func fileInode(fi fs.FileInfo) (uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Ino), true
+}
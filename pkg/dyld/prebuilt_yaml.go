@@ -0,0 +1,35 @@
+package dyld
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalYAML emits the same structure (and field names) as the set's JSON encoding
+func (pls PrebuiltLoaderSet) MarshalYAML() (any, error) {
+	dat, err := json.Marshal(pls)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is valid YAML, so decoding it into a node keeps the key order and the exact (64-bit) integers
+	var doc yaml.Node
+	if err := yaml.Unmarshal(dat, &doc); err != nil {
+		return nil, fmt.Errorf("failed to convert PrebuiltLoaderSet JSON to YAML: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("failed to convert PrebuiltLoaderSet JSON to YAML: empty document")
+	}
+	node := doc.Content[0]
+	blockStyle(node)
+	return node, nil
+}
+
+// blockStyle clears the (flow/quoted) JSON styles so the node is emitted as regular block YAML
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}